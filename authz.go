@@ -2,11 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -15,38 +18,23 @@ import (
 	"time"
 )
 
-// storeToken stores app_token in ~/.freebox_token
-func storeToken(token string, authInf *authInfo) error {
-	err := os.Setenv("FREEBOX_TOKEN", token)
-	if err != nil {
-		return err
-	}
+// grantPollInterval is the delay between two checks of the status
+// of an authorization request
+const grantPollInterval = 1 * time.Second
 
-	if _, err := os.Stat(authInf.myStore.location); os.IsNotExist(err) {
-		err := ioutil.WriteFile(authInf.myStore.location, []byte(token), 0600)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
+var (
+	errAuthorizationUnknown = errors.New("the app_token is invalid or has been revoked")
+	errAuthorizationTimeout = errors.New("the user did not confirmed the authorization within the given time")
+	errAuthorizationDenied  = errors.New("the user denied the authorization request")
+)
 
-// retreiveToken gets the token from file and
-// load it in environment variable
-func retreiveToken(authInf *authInfo) (string, error) {
-	if _, err := os.Stat(authInf.myStore.location); os.IsNotExist(err) {
-		return "", err
+// httpClient returns the HTTP client used to reach the Freebox,
+// the default one unless HTTPS has been enabled
+func (authInf *authInfo) httpClient() *http.Client {
+	if authInf.myClient == nil {
+		return http.DefaultClient
 	}
-	data, err := ioutil.ReadFile(authInf.myStore.location)
-	if err != nil {
-		return "", err
-	}
-	err = os.Setenv("FREEBOX_TOKEN", string(data))
-	if err != nil {
-		return "", err
-	}
-	return string(data), nil
+	return authInf.myClient
 }
 
 // getTrackID is the initial request to freebox API
@@ -54,10 +42,11 @@ func retreiveToken(authInf *authInfo) (string, error) {
 func getTrackID(authInf *authInfo) (*track, error) {
 	req, _ := json.Marshal(authInf.myApp)
 	buf := bytes.NewReader(req)
-	resp, err := http.Post(authInf.myAPI.authz, "application/json", buf)
+	resp, err := authInf.httpClient().Post(authInf.myAPI.authz, "application/json", buf)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -69,63 +58,140 @@ func getTrackID(authInf *authInfo) (*track, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !trackID.Success {
+		e := envelope{Msg: trackID.Msg, ErrorCode: trackID.ErrorCode}
+		return nil, e.status()
+	}
+	if trackID.Result.TrackID == 0 {
+		return nil, errors.New("the authorization request has no track_id")
+	}
+
+	return &trackID, nil
+}
+
+// getGrant reads the status of the authorization request at url
+func getGrant(authInf *authInfo, url string) (*grant, error) {
+	resp, err := authInf.httpClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-	err = storeToken(trackID.Result.AppToken, authInf)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	return &trackID, nil
+	granted := grant{}
+	err = json.Unmarshal(body, &granted)
+	if err != nil {
+		return nil, err
+	}
+	if !granted.Success {
+		e := envelope{Msg: granted.Msg, ErrorCode: granted.ErrorCode}
+		return nil, e.status()
+	}
+	if granted.Result.Status == "" {
+		return nil, errors.New("the authorization request has no status")
+	}
+	return &granted, nil
 }
 
 // getGranted waits for user to validate on the freebox front panel
-// with a timeout of 15 seconds
-func getGranted(authInf *authInfo) error {
+// for at most timeout, and returns the app_token once granted
+func getGranted(authInf *authInfo, timeout time.Duration) (*appToken, error) {
 	trackID, err := getTrackID(authInf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	url := authInf.myAPI.authz + strconv.Itoa(trackID.Result.TrackID)
-	for i := 0; i < 15; i++ {
-		resp, err := http.Get(url)
+	deadline := time.Now().Add(timeout)
+	status := ""
+	for {
+		granted, err := getGrant(authInf, url)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
+		if granted.Result.Status != status {
+			status = granted.Result.Status
+			log.Printf("track_id %d: %s", trackID.Result.TrackID, status)
 		}
 
-		granted := grant{}
-		err = json.Unmarshal(body, &granted)
-		if err != nil {
-			return err
-		}
-
-		switch granted.Result.Status {
+		switch status {
 		case "unknown":
-			return errors.New("the app_token is invalid or has been revoked")
+			return nil, errAuthorizationUnknown
 		case "pending":
 			log.Println("the user has not confirmed the authorization request yet")
 		case "timeout":
-			return errors.New("the user did not confirmed the authorization within the given time")
+			return nil, errAuthorizationTimeout
 		case "granted":
 			log.Println("the app_token is valid and can be used to open a session")
-			i = 15
+			return &appToken{
+				AppToken:  trackID.Result.AppToken,
+				AppID:     authInf.myApp.AppID,
+				TrackID:   trackID.Result.TrackID,
+				Endpoint:  authInf.myEndpoint,
+				UID:       authInf.myUID,
+				CreatedAt: time.Now().UTC(),
+			}, nil
 		case "denied":
-			return errors.New("the user denied the authorization request")
+			return nil, errAuthorizationDenied
 		}
-		time.Sleep(1 * time.Second)
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: still %s after %s", errAuthorizationTimeout, status, timeout)
+		}
+		time.Sleep(grantPollInterval)
 	}
-	return nil
+}
+
+// runAuthorize implements the authorize command which asks for an
+// app_token and exits with a distinct code for each failure
+func runAuthorize(authInf *authInfo, args []string) int {
+	flags := flag.NewFlagSet("authorize", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 2*time.Minute, "How long to wait for the authorization on the Freebox front panel")
+	flags.Parse(args)
+
+	log.Println("authorize the application on the Freebox front panel")
+	token, err := getGranted(authInf, *timeout)
+	if err == nil {
+		err = authInf.myStore.save(token)
+		if errors.Is(err, errReadOnlyStore) {
+			// the store is managed by someone else, hand the token over
+			log.Printf("%s is read-only, store the following token in it yourself", authInf.myStore)
+			data, _ := json.MarshalIndent(token, "", "  ")
+			fmt.Println(string(data))
+			err = nil
+		} else if err == nil {
+			log.Println("the app_token has been stored in", authInf.myStore)
+		}
+	}
+	if err == nil {
+		log.Println("check \"Modification des réglages de la Freebox\" for this application in Freebox OS")
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, "authorization failed:", err)
+	switch {
+	case errors.Is(err, errAuthorizationDenied):
+		return 2
+	case errors.Is(err, errAuthorizationTimeout):
+		return 3
+	case errors.Is(err, errAuthorizationUnknown):
+		return 4
+	}
+	return 1
 }
 
 // getChallenge makes sure the app always has a valid challenge
-func getChallenge(authInf *authInfo) (*challenge, error) {
-	resp, err := http.Get(authInf.myAPI.login)
+func getChallenge(ctx context.Context, authInf *authInfo) (*challenge, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", authInf.myAPI.login, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := authInf.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +217,7 @@ func hmacSha1(appToken, challenge string) string {
 }
 
 // getSession gets a session with freeebox API
-func getSession(authInf *authInfo, passwd string) (*sessionToken, error) {
+func getSession(ctx context.Context, authInf *authInfo, passwd string) (*sessionToken, error) {
 	s := session{
 		AppID:    authInf.myApp.AppID,
 		Password: passwd,
@@ -160,11 +226,16 @@ func getSession(authInf *authInfo, passwd string) (*sessionToken, error) {
 	if err != nil {
 		return nil, err
 	}
-	buf := bytes.NewReader(req)
-	resp, err := http.Post(authInf.myAPI.loginSession, "application/json", buf)
+	r, err := http.NewRequestWithContext(ctx, "POST", authInf.myAPI.loginSession, bytes.NewReader(req))
 	if err != nil {
 		return nil, err
 	}
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := authInf.httpClient().Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -178,44 +249,37 @@ func getSession(authInf *authInfo, passwd string) (*sessionToken, error) {
 	return &token, nil
 }
 
-// getToken gets a valid session_token and asks for user to change
-// the set of permissions on the API
-func getToken(authInf *authInfo, xSessionToken *string) (string, error) {
-	if _, err := os.Stat(authInf.myStore.location); os.IsNotExist(err) {
-		err = getGranted(authInf)
-		if err != nil {
-			return "", err
-		}
-
-		reader := authInf.myReader
-		log.Println("check \"Modification des réglages de la Freebox\" and press enter")
-		_, err = reader.ReadString('\n')
-		if err != nil {
-			return "", err
-		}
-	} else {
-		_, err := retreiveToken(authInf)
-		if err != nil {
-			return "", err
-		}
+// getToken gets a valid session_token with the app_token stored
+// by the authorize command, refusing a token issued by another Freebox
+func getToken(ctx context.Context, authInf *authInfo, xSessionToken *string) (string, error) {
+	stored, err := authInf.myStore.load()
+	if errors.Is(err, errNoToken) {
+		return "", fmt.Errorf("no app_token found in %s, run `freebox_exporter authorize` first", authInf.myStore)
 	}
-
-	token, err := getSessToken(os.Getenv("FREEBOX_TOKEN"), authInf, xSessionToken)
 	if err != nil {
 		return "", err
 	}
-	*xSessionToken = token
-	return token, nil
+
+	if stored.UID != "" && authInf.myUID != "" && stored.UID != authInf.myUID {
+		return "", fmt.Errorf("the app_token in %s was issued by the Freebox %q, not %q", authInf.myStore, stored.UID, authInf.myUID)
+	}
+	// without the uid of the box, only the endpoint the token was
+	// issued at tells it is the same one
+	if stored.UID != "" && authInf.myUID == "" && stored.Endpoint != authInf.myEndpoint {
+		return "", fmt.Errorf("the app_token in %s was issued by the Freebox %q at %s, which cannot be told apart from the one at %s", authInf.myStore, stored.UID, stored.Endpoint, authInf.myEndpoint)
+	}
+
+	return getSessToken(ctx, stored.AppToken, authInf, xSessionToken)
 }
 
 // getSessToken gets a new token session when the old one has expired
-func getSessToken(token string, authInf *authInfo, xSessionToken *string) (string, error) {
-	challenge, err := getChallenge(authInf)
+func getSessToken(ctx context.Context, token string, authInf *authInfo, xSessionToken *string) (string, error) {
+	challenge, err := getChallenge(ctx, authInf)
 	if err != nil {
 		return "", err
 	}
 	password := hmacSha1(token, challenge.Result.Challenge)
-	t, err := getSession(authInf, password)
+	t, err := getSession(ctx, authInf, password)
 	if err != nil {
 		return "", err
 	}
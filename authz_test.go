@@ -1,85 +1,21 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"strings"
 	"testing"
+	"time"
 )
 
-func TestRetreiveToken(t *testing.T) {
-	ai := &authInfo{
-		myStore: store{location: "/tmp/token"},
-	}
-
-	_, err := retreiveToken(ai)
-	if err.Error() != "stat /tmp/token: no such file or directory" {
-		t.Error("Expected bla, but got", err)
-	}
-
-	ioutil.WriteFile(ai.myStore.location, []byte("IOI"), 0600)
-	defer os.Remove(ai.myStore.location)
-
-	token, err := retreiveToken(ai)
-	if err != nil {
-		t.Error("Expected no err, but got", err)
-	}
-
-	newToken := os.Getenv("FREEBOX_TOKEN")
-
-	if newToken != "IOI" {
-		t.Error("Expected IOI, but got", newToken)
-	}
-
-	if token != "IOI" {
-		t.Error("Expected IOI, but got", newToken)
-	}
-
-	os.Unsetenv("FREEBOX_TOKEN")
-}
-
-func TestStoreToken(t *testing.T) {
-	var token string
-
-	ai := &authInfo{}
-	token = "IOI"
-	err := storeToken(token, ai)
-	if err.Error() != "open : no such file or directory" {
-		t.Error("Expected open : no such file or directory, but got", err)
-	}
-
-	ai.myStore.location = "/tmp/token"
-	err = storeToken(token, ai)
-	if err != nil {
-		t.Error("Expected no err, but got", err)
-	}
-	defer os.Remove(ai.myStore.location)
-
-	token = os.Getenv("FREEBOX_TOKEN")
-	if token != "IOI" {
-		t.Error("Expected IOI, but got", token)
-	}
-	os.Unsetenv("FREEBOX_TOKEN")
-
-	data, err := ioutil.ReadFile(ai.myStore.location)
-	if err != nil {
-		t.Error("Expected no err, but got", err)
-	}
-
-	if string(data) != "IOI" {
-		t.Error("Expected IOI, but got", string(data))
-	}
-
-}
-
 func TestGetTrackID(t *testing.T) {
 	ai := &authInfo{
-		myStore: store{location: "/tmp/token"},
+		myStore: &fileStore{location: "/tmp/token"},
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,18 +34,18 @@ func TestGetTrackID(t *testing.T) {
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
-	defer os.Remove(ai.myStore.location)
-	defer os.Unsetenv("FREEBOX_TOKEN")
 
 	if trackID.Result.TrackID != 101 {
 		t.Error("Expected 101, but got", trackID.Result.TrackID)
 	}
 
-	// as getTrackID have no return value
-	// the result of storeToken func is checked instead
-	token := os.Getenv("FREEBOX_TOKEN")
-	if token != "IOI" {
-		t.Error("Expected IOI, but got", token)
+	if trackID.Result.AppToken != "IOI" {
+		t.Error("Expected IOI, but got", trackID.Result.AppToken)
+	}
+
+	// the app_token is only stored once granted
+	if _, err := os.Stat("/tmp/token"); !os.IsNotExist(err) {
+		t.Error("Expected no token stored, but got", err)
 	}
 }
 
@@ -164,6 +100,7 @@ func TestGetGranted(t *testing.T) {
 				Success: true,
 			}
 			myTrack.Result.TrackID = 101
+			myTrack.Result.AppToken = "IOI"
 			result, _ := json.Marshal(myTrack)
 			fmt.Fprintln(w, string(result))
 		case "/granted/101":
@@ -173,6 +110,55 @@ func TestGetGranted(t *testing.T) {
 			myGrant.Result.Status = "granted"
 			result, _ := json.Marshal(myGrant)
 			fmt.Fprintln(w, string(result))
+		case "/pending/":
+			myTrack := track{
+				Success: true,
+			}
+			myTrack.Result.TrackID = 101
+			result, _ := json.Marshal(myTrack)
+			fmt.Fprintln(w, string(result))
+		case "/pending/101":
+			myGrant := grant{
+				Success: true,
+			}
+			myGrant.Result.Status = "pending"
+			result, _ := json.Marshal(myGrant)
+			fmt.Fprintln(w, string(result))
+		case "/refused/":
+			myTrack := track{
+				Success:   false,
+				ErrorCode: "denied_from_external_ip",
+			}
+			result, _ := json.Marshal(myTrack)
+			fmt.Fprintln(w, string(result))
+		case "/forgotten/":
+			myTrack := track{
+				Success: true,
+			}
+			myTrack.Result.TrackID = 101
+			result, _ := json.Marshal(myTrack)
+			fmt.Fprintln(w, string(result))
+		case "/forgotten/101":
+			myGrant := grant{
+				Success:   false,
+				Msg:       "Invalid track_id",
+				ErrorCode: "invalid_request",
+			}
+			result, _ := json.Marshal(myGrant)
+			fmt.Fprintln(w, string(result))
+		case "/empty/":
+			myTrack := track{
+				Success: true,
+			}
+			myTrack.Result.TrackID = 101
+			result, _ := json.Marshal(myTrack)
+			fmt.Fprintln(w, string(result))
+		case "/empty/101":
+			myGrant := grant{
+				Success: true,
+			}
+			result, _ := json.Marshal(myGrant)
+			fmt.Fprintln(w, string(result))
 		default:
 			fmt.Fprintln(w, http.StatusNotFound)
 		}
@@ -181,31 +167,74 @@ func TestGetGranted(t *testing.T) {
 
 	ai := authInfo{}
 	ai.myAPI.authz = ts.URL + "/unknown/"
-	ai.myStore.location = "/tmp/token"
+	ai.myEndpoint = ts.URL + "/"
+	ai.myUID = "0123456789abcdef"
 
-	err := getGranted(&ai)
+	_, err := getGranted(&ai, time.Minute)
 	if err.Error() != "the app_token is invalid or has been revoked" {
 		t.Error("Expected the app_token is invalid or has been revoked, but got", err)
 	}
-	defer os.Remove(ai.myStore.location)
-	defer os.Unsetenv("FREEBOX_TOKEN")
 
 	ai.myAPI.authz = ts.URL + "/timeout/"
-	err = getGranted(&ai)
+	_, err = getGranted(&ai, time.Minute)
 	if err.Error() != "the user did not confirmed the authorization within the given time" {
 		t.Error("Expected the user did not confirmed the authorization within the given time, but got", err)
 	}
 
 	ai.myAPI.authz = ts.URL + "/denied/"
-	err = getGranted(&ai)
+	_, err = getGranted(&ai, time.Minute)
 	if err.Error() != "the user denied the authorization request" {
 		t.Error("Expected the user denied the authorization request, but got", err)
 	}
 
+	ai.myAPI.authz = ts.URL + "/refused/"
+	_, err = getGranted(&ai, time.Minute)
+	if err != apiErrors["denied_from_external_ip"] {
+		t.Error("Expected You are trying to get an app_token from a remote IP, but got", err)
+	}
+
+	ai.myAPI.authz = ts.URL + "/forgotten/"
+	_, err = getGranted(&ai, time.Minute)
+	if err != apiErrors["invalid_request"] {
+		t.Error("Expected Your request is invalid, but got", err)
+	}
+
+	ai.myAPI.authz = ts.URL + "/empty/"
+	_, err = getGranted(&ai, time.Minute)
+	if err == nil || err.Error() != "the authorization request has no status" {
+		t.Error("Expected the authorization request has no status, but got", err)
+	}
+
+	ai.myAPI.authz = ts.URL + "/pending/"
+	_, err = getGranted(&ai, 0)
+	if !errors.Is(err, errAuthorizationTimeout) {
+		t.Error("Expected the user did not confirmed the authorization within the given time, but got", err)
+	}
+
 	ai.myAPI.authz = ts.URL + "/granted/"
-	err = getGranted(&ai)
+	token, err := getGranted(&ai, time.Minute)
 	if err != nil {
-		t.Error("Expected no err, but got", err)
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	if token.AppToken != "IOI" {
+		t.Error("Expected IOI, but got", token.AppToken)
+	}
+
+	if token.TrackID != 101 {
+		t.Error("Expected 101, but got", token.TrackID)
+	}
+
+	if token.UID != "0123456789abcdef" {
+		t.Error("Expected 0123456789abcdef, but got", token.UID)
+	}
+
+	if token.Endpoint != ts.URL+"/" {
+		t.Error("Expected", ts.URL+"/", "but got", token.Endpoint)
+	}
+
+	if token.CreatedAt.IsZero() {
+		t.Error("Expected a creation time, but got", token.CreatedAt)
 	}
 }
 
@@ -227,7 +256,7 @@ func TestGetChallenge(t *testing.T) {
 		},
 	}
 
-	challenged, err := getChallenge(ai)
+	challenged, err := getChallenge(context.Background(), ai)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -266,7 +295,7 @@ func TestGetSession(t *testing.T) {
 		},
 	}
 
-	token, err := getSession(ai, "")
+	token, err := getSession(context.Background(), ai, "")
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -299,20 +328,6 @@ func TestGetToken(t *testing.T) {
 			myToken.Result.SessionToken = "foobar"
 			result, _ := json.Marshal(myToken)
 			fmt.Fprintln(w, string(result))
-		case "/granted/":
-			myTrack := track{
-				Success: true,
-			}
-			myTrack.Result.TrackID = 101
-			result, _ := json.Marshal(myTrack)
-			fmt.Fprintln(w, string(result))
-		case "/granted/101":
-			myGrant := grant{
-				Success: true,
-			}
-			myGrant.Result.Status = "granted"
-			result, _ := json.Marshal(myGrant)
-			fmt.Fprintln(w, string(result))
 		default:
 			fmt.Fprintln(w, http.StatusNotFound)
 		}
@@ -320,33 +335,50 @@ func TestGetToken(t *testing.T) {
 	defer ts.Close()
 
 	ai := authInfo{}
-	ai.myStore.location = "/tmp/token"
+	ai.myStore = &fileStore{location: "/tmp/token"}
+	ai.myUID = "0123456789abcdef"
 	ai.myAPI.login = ts.URL + "/login"
 	ai.myAPI.loginSession = ts.URL + "/session"
-	ai.myAPI.authz = ts.URL + "/granted/"
-	ai.myReader = bufio.NewReader(strings.NewReader("\n"))
 
 	var mySessionToken string
 
-	// the first pass valide getToken without a token stored in a file
-	tk, err := getToken(&ai, &mySessionToken)
-	if err != nil {
-		t.Error("Expected no err, but got", err)
+	// the first pass fails as no token has been stored by authorize
+	_, err := getToken(context.Background(), &ai, &mySessionToken)
+	if err.Error() != "no app_token found in file:/tmp/token, run `freebox_exporter authorize` first" {
+		t.Error("Expected no app_token found in file:/tmp/token, run `freebox_exporter authorize` first, but got", err)
 	}
-	defer os.Remove(ai.myStore.location)
-	defer os.Unsetenv("FREEBOX_TOKEN")
 
-	if mySessionToken != "foobar" {
-		t.Error("Expected foobar, but got", mySessionToken)
+	// the second pass fails as the token belongs to another Freebox
+	ioutil.WriteFile("/tmp/token", []byte(`{"app_token":"IOI","uid":"fedcba9876543210"}`), 0600)
+	defer os.Remove("/tmp/token")
+
+	_, err = getToken(context.Background(), &ai, &mySessionToken)
+	if err == nil || err.Error() != `the app_token in file:/tmp/token was issued by the Freebox "fedcba9876543210", not "0123456789abcdef"` {
+		t.Error("Expected the app_token to be refused, but got", err)
 	}
 
-	if tk != "foobar" {
-		t.Error("Expected foobar, but got", tk)
+	// the uid of the box is unknown and the token was issued elsewhere
+	ai.myUID = ""
+	ai.myEndpoint = "http://192.168.1.254/"
+	ioutil.WriteFile("/tmp/token", []byte(`{"app_token":"IOI","uid":"0123456789abcdef","endpoint":"http://mafreebox.freebox.fr/"}`), 0600)
+
+	_, err = getToken(context.Background(), &ai, &mySessionToken)
+	if err == nil || err.Error() != `the app_token in file:/tmp/token was issued by the Freebox "0123456789abcdef" at http://mafreebox.freebox.fr/, which cannot be told apart from the one at http://192.168.1.254/` {
+		t.Error("Expected the app_token to be refused, but got", err)
 	}
 
-	// the second pass validate getToken with a token stored in a file:
-	// the first pass creates a file at ai.myStore.location
-	tk, err = getToken(&ai, &mySessionToken)
+	// but it was issued at the same endpoint
+	ai.myEndpoint = "http://mafreebox.freebox.fr/"
+	_, err = getToken(context.Background(), &ai, &mySessionToken)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// the third pass validate getToken with a token stored in a file
+	ai.myUID = "0123456789abcdef"
+	ioutil.WriteFile("/tmp/token", []byte(`{"app_token":"IOI","uid":"0123456789abcdef"}`), 0600)
+
+	tk, err := getToken(context.Background(), &ai, &mySessionToken)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -395,7 +427,7 @@ func TestGetSessToken(t *testing.T) {
 	ai.myAPI.loginSession = ts.URL + "/session"
 	var mySessionToken string
 
-	st, err := getSessToken("token", &ai, &mySessionToken)
+	st, err := getSessToken(context.Background(), "token", &ai, &mySessionToken)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -406,7 +438,7 @@ func TestGetSessToken(t *testing.T) {
 
 	ai.myAPI.loginSession = ts.URL + "/session2"
 
-	_, err = getSessToken("token", &ai, &mySessionToken)
+	_, err = getSessToken(context.Background(), "token", &ai, &mySessionToken)
 	if err.Error() != "failed to get a session" {
 		t.Error("Expected but got failed to get a session, but got", err)
 	}
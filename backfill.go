@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelValueEscaper escapes the label values in the OpenMetrics format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// sample is a value of a series at a given time (in seconds)
+type sample struct {
+	time  int64
+	value float64
+}
+
+// family gathers the series of a metric name
+type family struct {
+	name   string
+	help   string
+	typ    prometheus.ValueType
+	series map[string][]sample // by label set
+}
+
+// runBackfill implements the backfill command which writes the history
+// of the RRD fields of metrics as OpenMetrics, to be imported with
+// promtool tsdb create-blocks-from openmetrics
+func runBackfill(c *client, metrics []rrdMetric, labels map[string]string, args []string) int {
+	all := []string{}
+	seen := map[string]bool{}
+	for _, m := range metrics {
+		if !seen[m.DB] {
+			seen[m.DB] = true
+			all = append(all, m.DB)
+		}
+	}
+
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	dbs := flags.String("db", strings.Join(all, ","), "Comma separated list of the RRD databases to export")
+	output := flags.String("o", "-", "File to write the OpenMetrics to, - for the standard output")
+	flags.Parse(args)
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "backfill failed:", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	err := backfill(context.Background(), c, metrics, strings.Split(*dbs, ","), labels, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "backfill failed:", err)
+		return 1
+	}
+	return 0
+}
+
+// backfill writes the history of the fields of metrics in the RRD
+// databases dbs, at every precision, with labels added to every series
+func backfill(ctx context.Context, c *client, metrics []rrdMetric, dbs []string, labels map[string]string, w io.Writer) error {
+	families := map[string]*family{}
+	names := []string{}
+
+	for _, db := range dbs {
+		dbMetrics := []rrdMetric{}
+		fields := []string{}
+		for _, m := range metrics {
+			if m.DB == db {
+				dbMetrics = append(dbMetrics, m)
+				fields = append(fields, m.Field)
+			}
+		}
+		if len(dbMetrics) == 0 {
+			return fmt.Errorf("unknown RRD database %q", db)
+		}
+
+		// the coarser precisions only fill the history older than the
+		// points of the finer ones
+		before := time.Now().Unix()
+		for _, precision := range rrdPrecisions {
+			history, err := getRRDHistory(ctx, c, db, fields, precision, before)
+			if err != nil {
+				return fmt.Errorf("%s database: %v", db, err)
+			}
+			if len(history) == 0 {
+				continue
+			}
+			log.Printf("%s database: %d points every %ds", db, len(history), precision)
+
+			for _, m := range dbMetrics {
+				name := m.fullName()
+				f, ok := families[name]
+				if !ok {
+					f = &family{name: name, help: m.Help, typ: m.valueType(), series: map[string][]sample{}}
+					families[name] = f
+					names = append(names, name)
+				}
+
+				key := formatLabels(m.Labels, labels)
+				for _, point := range history {
+					raw, ok := point[m.Field]
+					if !ok {
+						continue
+					}
+					f.series[key] = append(f.series[key], sample{time: point["time"], value: m.value(raw)})
+				}
+			}
+			before = history[0]["time"]
+		}
+	}
+
+	for _, name := range names {
+		if err := writeFamily(w, families[name]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "# EOF")
+	return err
+}
+
+// writeFamily writes the samples of f from the oldest to the newest, the
+// name of a counter family has no _total suffix but its samples do
+func writeFamily(w io.Writer, f *family) error {
+	name, typ := f.name, "gauge"
+	if f.typ == prometheus.CounterValue {
+		name, typ = strings.TrimSuffix(f.name, "_total"), "counter"
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, typ); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		samples := f.series[key]
+		sort.Slice(samples, func(i, j int) bool { return samples[i].time < samples[j].time })
+		for _, s := range samples {
+			value := strconv.FormatFloat(s.value, 'g', -1, 64)
+			if _, err := fmt.Fprintf(w, "%s%s %s %d\n", f.name, key, value, s.time); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatLabels formats the union of the label sets in the OpenMetrics
+// format, sorted by name
+func formatLabels(sets ...map[string]string) string {
+	labels := map[string]string{}
+	for _, set := range sets {
+		for name, value := range set {
+			labels[name] = value
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelValueEscaper.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newHistoryServer serves the points of the temp database, every
+// precision seconds from start to end
+func newHistoryServer(points map[int][2]int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := database{}
+		json.NewDecoder(r.Body).Decode(&d)
+
+		result := rrd{Data: []map[string]int64{}}
+		if d.DB == "temp" {
+			bounds := points[d.Precision]
+			for t := bounds[0]; t <= bounds[1]; t += int64(d.Precision) {
+				if t >= int64(d.DateStart) && t <= int64(d.DateEnd) {
+					result.Data = append(result.Data, map[string]int64{
+						"time": t, "cpum": 60, "cpub": 61, "sw": 50, "hdd": 40, "fan_speed": 1200,
+					})
+				}
+			}
+		}
+		writeResult(w, result)
+	}))
+}
+
+func TestBackfill(t *testing.T) {
+	now := time.Now().Unix()
+	ts := newHistoryServer(map[int][2]int64{
+		10: {now - 20, now - 10},
+		60: {now - 140, now - 20},
+	})
+	defer ts.Close()
+
+	w := &bytes.Buffer{}
+	err := backfill(context.Background(), newTestClient(ts.URL), rrdTempMetrics, []string{"temp"}, map[string]string{"box": "paris"}, w)
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	// the points every 60s stop where the points every 10s begin
+	expected := fmt.Sprintf(`# HELP freebox_system_fan_rpm Fan speed reported by system (in RPM)
+# TYPE freebox_system_fan_rpm gauge
+freebox_system_fan_rpm{box="paris",name="Ventilateur 1"} 1200 %d
+freebox_system_fan_rpm{box="paris",name="Ventilateur 1"} 1200 %d
+freebox_system_fan_rpm{box="paris",name="Ventilateur 1"} 1200 %d
+freebox_system_fan_rpm{box="paris",name="Ventilateur 1"} 1200 %d
+# EOF
+`, now-140, now-80, now-20, now-10)
+
+	output := w.String()
+	if !strings.HasSuffix(output, expected) {
+		t.Error("Expected", expected, "but got", output)
+	}
+
+	if !strings.Contains(output, fmt.Sprintf(`freebox_system_temp_celsius{box="paris",name="Disque dur"} 40 %d`, now-10)) {
+		t.Error("Expected the temperature of the hard drive, but got", output)
+	}
+}
+
+func TestBackfillPages(t *testing.T) {
+	now := time.Now().Unix()
+	ts := newHistoryServer(map[int][2]int64{
+		10: {now - 10*(3*rrdPageSize-1), now - 10},
+	})
+	defer ts.Close()
+
+	w := &bytes.Buffer{}
+	err := backfill(context.Background(), newTestClient(ts.URL), rrdTempMetrics, []string{"temp"}, nil, w)
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	count := strings.Count(w.String(), "freebox_system_fan_rpm{name=\"Ventilateur 1\"}")
+	if count != 3*rrdPageSize-1 {
+		t.Error("Expected", 3*rrdPageSize-1, "but got", count)
+	}
+
+	err = backfill(context.Background(), newTestClient(ts.URL), rrdTempMetrics, []string{"fbxconnman"}, nil, w)
+	if err == nil || err.Error() != `unknown RRD database "fbxconnman"` {
+		t.Error(`Expected unknown RRD database "fbxconnman", but got`, err)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	labels := formatLabels(map[string]string{"name": `Disque "dur"`}, map[string]string{"box": "paris"})
+	if labels != `{box="paris",name="Disque \"dur\""}` {
+		t.Error(`Expected {box="paris",name="Disque \"dur\""}, but got`, labels)
+	}
+
+	if formatLabels(nil) != "" {
+		t.Error("Expected no label, but got", formatLabels(nil))
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// missingTTL is how long a path which no API version answers is
+// reported missing without asking the Freebox again
+const missingTTL = 10 * time.Minute
+
+var (
+	apiErrors = map[string]error{
+		"invalid_token":           errors.New("The app token you are trying to use is invalid or has been revoked"),
+		"insufficient_rights":     errors.New("Your app permissions does not allow accessing this API"),
+		"denied_from_external_ip": errors.New("You are trying to get an app_token from a remote IP"),
+		"invalid_request":         errors.New("Your request is invalid"),
+		"ratelimited":             errors.New("Too many auth error have been made from your IP"),
+		"new_apps_denied":         errors.New("New application token request has been disabled"),
+		"apps_denied":             errors.New("API access from apps has been disabled"),
+		"internal_error":          errors.New("Internal error"),
+		"db_error":                errors.New("Oops, the database you are trying to access doesn't seem to exist"),
+		"nodev":                   errors.New("Invalid interface"),
+	}
+
+	errNotFound = errors.New("404 Not Found")
+
+	// defaultAPIVersion is used when the Freebox does not answer
+	// /api_version
+	defaultAPIVersion = apiVersion{
+		APIBaseURL: "/api/",
+		APIVersion: "4.0",
+	}
+)
+
+// client is the single entry point to the Freebox API: it owns the
+// base URL, the X-Fbx-App-Auth session header, the response envelope
+// and renews the session when the API answers auth_required
+type client struct {
+	endpoint   string
+	authInf    *authInfo
+	httpClient *http.Client
+	version    apiVersion
+
+	mu           sync.Mutex
+	sessionToken string
+
+	versionsMu sync.Mutex
+	versions   map[string]int       // API version answering each path
+	missing    map[string]time.Time // paths no version answers, until when
+}
+
+// envelope is the {success, result, error_code, msg} wrapper shared
+// by every answer of the Freebox API
+type envelope struct {
+	Success   bool            `json:"success"`
+	Msg       string          `json:"msg,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+}
+
+func newClient(endpoint string, authInf *authInfo) *client {
+	return &client{
+		endpoint:   endpoint,
+		authInf:    authInf,
+		httpClient: &http.Client{},
+		version:    defaultAPIVersion,
+		versions:   map[string]int{},
+		missing:    map[string]time.Time{},
+	}
+}
+
+// discover reads the API base URL and version from /api_version
+func (c *client) discover(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint+"api_version", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.New(resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	version := apiVersion{}
+	err = json.Unmarshal(data, &version)
+	if err != nil {
+		if debug {
+			log.Println(string(data))
+		}
+		return err
+	}
+	if version.APIBaseURL == "" || version.major() == 0 {
+		return errors.New("the Freebox answers an invalid api_version: " + string(data))
+	}
+
+	c.version = version
+	return nil
+}
+
+// major returns the major number of the API version, 6 for "6.0"
+func (v apiVersion) major() int {
+	major, _ := strconv.Atoi(strings.SplitN(v.APIVersion, ".", 2)[0])
+	return major
+}
+
+// url returns the URL of path on the given major API version
+func (c *client) url(version int, path string) string {
+	return c.endpoint + strings.TrimPrefix(c.version.APIBaseURL, "/") +
+		"v" + strconv.Itoa(version) + "/" + path
+}
+
+// candidates lists the API versions to try for path: the one which
+// last answered, or the latest one, then every older version
+func (c *client) candidates(path string) []int {
+	c.versionsMu.Lock()
+	latest, ok := c.versions[path]
+	c.versionsMu.Unlock()
+	if !ok {
+		latest = c.version.major()
+	}
+
+	versions := []int{}
+	for v := latest; v > 0; v-- {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// status translates the error_code of an envelope into an error
+func (e *envelope) status() error {
+	if err, ok := apiErrors[e.ErrorCode]; ok {
+		return err
+	}
+	if e.Msg != "" {
+		return errors.New(e.Msg)
+	}
+	return errors.New("The API returns an unknown error_code: " + e.ErrorCode)
+}
+
+// get decodes the result of a GET on path into result
+func (c *client) get(ctx context.Context, path string, result interface{}) error {
+	return c.do(ctx, "GET", path, nil, result)
+}
+
+// post sends body as JSON to path and decodes the result into result
+func (c *client) post(ctx context.Context, path string, body, result interface{}) error {
+	return c.do(ctx, "POST", path, body, result)
+}
+
+// do performs an authenticated request, opens a new session and
+// retries once if the current one has expired
+func (c *client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	token, err := c.session(ctx)
+	if err != nil {
+		return err
+	}
+
+	env, err := c.send(ctx, method, path, body, token)
+	if err != nil {
+		return err
+	}
+
+	if env.ErrorCode == "auth_required" {
+		token, err = c.renew(ctx, token)
+		if err != nil {
+			return err
+		}
+		env, err = c.send(ctx, method, path, body, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !env.Success || env.ErrorCode != "" {
+		return env.status()
+	}
+
+	if result == nil || len(env.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(env.Result, result)
+}
+
+// send tries path on each candidate API version until the Freebox
+// stops answering that it does not exist
+func (c *client) send(ctx context.Context, method, path string, body interface{}, token string) (*envelope, error) {
+	c.versionsMu.Lock()
+	until, missing := c.missing[path]
+	c.versionsMu.Unlock()
+	if missing && time.Now().Before(until) {
+		return nil, errNotFound
+	}
+
+	err := errNotFound
+	for _, version := range c.candidates(path) {
+		var env *envelope
+		env, err = c.request(ctx, method, c.url(version, path), body, token)
+		if err == errNotFound {
+			continue
+		}
+		if err == nil {
+			c.versionsMu.Lock()
+			c.versions[path] = version
+			delete(c.missing, path)
+			c.versionsMu.Unlock()
+		}
+		return env, err
+	}
+
+	if err == errNotFound {
+		c.versionsMu.Lock()
+		c.missing[path] = time.Now().Add(missingTTL)
+		c.versionsMu.Unlock()
+	}
+	return nil, err
+}
+
+// request sends a single request and decodes the envelope of the answer
+func (c *client) request(ctx context.Context, method, url string, body interface{}, token string) (*envelope, error) {
+	var buf io.Reader
+	if body != nil {
+		r, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		buf = bytes.NewReader(r)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("X-Fbx-App-Auth", token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, errNotFound
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{}
+	err = json.Unmarshal(data, env)
+	if err != nil {
+		if debug {
+			log.Println(string(data))
+		}
+		return nil, err
+	}
+	if env.ErrorCode == "invalid_api_version" {
+		return nil, errNotFound
+	}
+	return env, nil
+}
+
+// session returns the current session token, asking for an app_token
+// and opening a session the first time it is called
+func (c *client) session(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sessionToken != "" {
+		return c.sessionToken, nil
+	}
+
+	_, err := getToken(ctx, c.authInf, &c.sessionToken)
+	return c.sessionToken, err
+}
+
+// renew opens a new session when expired is still the current one,
+// otherwise another request already did it
+func (c *client) renew(ctx context.Context, expired string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sessionToken != expired {
+		return c.sessionToken, nil
+	}
+
+	_, err := getToken(ctx, c.authInf, &c.sessionToken)
+	return c.sessionToken, err
+}
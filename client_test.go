@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// writeResult answers with a successful envelope around result
+func writeResult(w http.ResponseWriter, result interface{}) {
+	r, _ := json.Marshal(result)
+	e, _ := json.Marshal(envelope{Success: true, Result: r})
+	fmt.Fprintln(w, string(e))
+}
+
+// writeError answers with a failed envelope carrying errorCode
+func writeError(w http.ResponseWriter, errorCode string) {
+	e, _ := json.Marshal(envelope{Success: false, ErrorCode: errorCode})
+	fmt.Fprintln(w, string(e))
+}
+
+// newTestClient returns a client with an already opened session
+func newTestClient(url string) *client {
+	c := newClient(url+"/", &authInfo{myStore: &envStore{name: "FREEBOX_TOKEN"}})
+	c.sessionToken = "foobar"
+	return c
+}
+
+func TestClientSession(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/login":
+			myChall := &challenge{
+				Success: true,
+			}
+			myChall.Result.Challenge = "foobar"
+			result, _ := json.Marshal(myChall)
+			fmt.Fprintln(w, string(result))
+		case "/session":
+			myToken := sessionToken{
+				Success: true,
+			}
+			myToken.Result.SessionToken = "foobar"
+			result, _ := json.Marshal(myToken)
+			fmt.Fprintln(w, string(result))
+		default:
+			fmt.Fprintln(w, http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ai := &authInfo{}
+	ai.myStore = &fileStore{location: "/tmp/token"}
+	ai.myAPI.login = ts.URL + "/login"
+	ai.myAPI.loginSession = ts.URL + "/session"
+
+	ioutil.WriteFile("/tmp/token", []byte("IOI"), 0600)
+	defer os.Remove("/tmp/token")
+
+	c := newClient(ts.URL+"/", ai)
+
+	token, err := c.session(context.Background())
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if token != "foobar" {
+		t.Error("Expected foobar, but got", token)
+	}
+
+	c.sessionToken = "barfoo"
+	token, err = c.session(context.Background())
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if token != "barfoo" {
+		t.Error("Expected barfoo, but got", token)
+	}
+
+	// opening a session gives up with the context of the scrape
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.sessionToken = ""
+	_, err = c.session(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Error("Expected context canceled, but got", err)
+	}
+}
+
+func TestClientDo(t *testing.T) {
+	os.Setenv("FREEBOX_TOKEN", "IOI")
+	defer os.Unsetenv("FREEBOX_TOKEN")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/login":
+			myChall := &challenge{
+				Success: true,
+			}
+			myChall.Result.Challenge = "foobar"
+			result, _ := json.Marshal(myChall)
+			fmt.Fprintln(w, string(result))
+		case "/session":
+			myToken := sessionToken{
+				Success: true,
+			}
+			myToken.Result.SessionToken = "renewed"
+			result, _ := json.Marshal(myToken)
+			fmt.Fprintln(w, string(result))
+		case "/api/v4/good":
+			writeResult(w, map[string]string{"header": r.Header.Get("X-Fbx-App-Auth")})
+		case "/api/v4/expired":
+			if r.Header.Get("X-Fbx-App-Auth") != "renewed" {
+				writeError(w, "auth_required")
+				return
+			}
+			writeResult(w, map[string]string{"header": r.Header.Get("X-Fbx-App-Auth")})
+		case "/api/v4/error":
+			writeError(w, "insufficient_rights")
+		case "/api/v4/unknown":
+			writeError(w, "foobar")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := newTestClient(ts.URL)
+	c.authInf.myAPI.login = ts.URL + "/login"
+	c.authInf.myAPI.loginSession = ts.URL + "/session"
+
+	result := map[string]string{}
+	err := c.get(context.Background(), "good", &result)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if result["header"] != "foobar" {
+		t.Error("Expected foobar, but got", result["header"])
+	}
+
+	err = c.get(context.Background(), "expired", &result)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if result["header"] != "renewed" {
+		t.Error("Expected renewed, but got", result["header"])
+	}
+
+	if c.sessionToken != "renewed" {
+		t.Error("Expected renewed, but got", c.sessionToken)
+	}
+
+	err = c.get(context.Background(), "error", &result)
+	if err.Error() != "Your app permissions does not allow accessing this API" {
+		t.Error("Expected Your app permissions does not allow accessing this API, but got", err)
+	}
+
+	err = c.get(context.Background(), "unknown", &result)
+	if err.Error() != "The API returns an unknown error_code: foobar" {
+		t.Error("Expected The API returns an unknown error_code: foobar, but got", err)
+	}
+
+	err = c.get(context.Background(), "missing", &result)
+	if err.Error() != "404 Not Found" {
+		t.Error("Expected 404 Not Found, but got", err)
+	}
+}
+
+func TestClientDiscover(t *testing.T) {
+	notFound := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api_version":
+			myVersion := apiVersion{
+				APIVersion:   "8.2",
+				APIBaseURL:   "/api/",
+				BoxModel:     "fbxgw7-r1/full",
+				BoxModelName: "Freebox v7 (r1)",
+			}
+			result, _ := json.Marshal(myVersion)
+			fmt.Fprintln(w, string(result))
+		case "/api/v8/system/":
+			writeResult(w, system{FanRPM: 666})
+		case "/api/v6/wifi/ap/":
+			writeResult(w, []wifiAccessPoint{{Name: "AP1"}})
+		default:
+			notFound++
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := newTestClient(ts.URL)
+
+	if c.version.major() != 4 {
+		t.Error("Expected 4, but got", c.version.major())
+	}
+
+	err := c.discover(context.Background())
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if c.version.major() != 8 {
+		t.Error("Expected 8, but got", c.version.major())
+	}
+
+	if c.version.BoxModel != "fbxgw7-r1/full" {
+		t.Error("Expected fbxgw7-r1/full, but got", c.version.BoxModel)
+	}
+
+	systemStats, err := getSystem(context.Background(), c)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if systemStats.FanRPM != 666 {
+		t.Error("Expected 666, but got", systemStats.FanRPM)
+	}
+
+	// wifi/ap/ only exists on v6, v8 and v7 answer 404
+	wifiStats, err := getWifi(context.Background(), c)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if len(wifiStats) != 1 || wifiStats[0].Name != "AP1" {
+		t.Error("Expected AP1, but got", wifiStats)
+	}
+
+	if c.versions["wifi/ap/"] != 6 {
+		t.Error("Expected 6, but got", c.versions["wifi/ap/"])
+	}
+
+	// a path answered by no version is only tried once
+	notFound = 0
+	for i := 0; i < 2; i++ {
+		err = c.get(context.Background(), "missing/", nil)
+		if err != errNotFound {
+			t.Error("Expected 404 Not Found, but got", err)
+		}
+	}
+	if notFound != 8 {
+		t.Error("Expected 8, but got", notFound)
+	}
+
+	// until it has been missing for missingTTL
+	c.missing["missing/"] = time.Now()
+	err = c.get(context.Background(), "missing/", nil)
+	if err != errNotFound {
+		t.Error("Expected 404 Not Found, but got", err)
+	}
+	if notFound != 16 {
+		t.Error("Expected 16, but got", notFound)
+	}
+}
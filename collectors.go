@@ -0,0 +1,1367 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collector fetches the metrics of one Freebox subsystem
+type collector interface {
+	update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error
+}
+
+// collectorFactories lists every collector by the name used in the
+// configuration and in freebox_scrape_collector_success
+var collectorFactories = map[string]func(cfg *config) collector{
+	"freeplug":          func(cfg *config) collector { return freeplugCollector{} },
+	"rrd":               func(cfg *config) collector { return newRRDCollector(cfg) },
+	"lan":               func(cfg *config) collector { return lanCollector{} },
+	"switch":            func(cfg *config) collector { return switchCollector{} },
+	"system":            func(cfg *config) collector { return systemCollector{compat: cfg.CompatGauges} },
+	"wifi":              func(cfg *config) collector { return wifiCollector{compat: cfg.CompatGauges} },
+	"wifi_survey":       func(cfg *config) collector { return wifiSurveyCollector{} },
+	"vpn":               func(cfg *config) collector { return vpnCollector{compat: cfg.CompatGauges} },
+	"connection":        func(cfg *config) collector { return connectionCollector{} },
+	"connection_config": func(cfg *config) collector { return connectionConfigCollector{} },
+	"dhcp":              func(cfg *config) collector { return dhcpCollector{} },
+	"firewall":          func(cfg *config) collector { return firewallCollector{} },
+	"xdsl":              func(cfg *config) collector { return xdslCollector{compat: cfg.CompatGauges} },
+	"ftth":              func(cfg *config) collector { return ftthCollector{} },
+}
+
+// lineCollector is a collector which only makes sense on a WAN media
+type lineCollector interface {
+	collector
+	media() string
+}
+
+// mediaCollector is a collector with some metrics which only make sense
+// on a WAN media, it finds the media of the scrape with wanMediaOf
+type mediaCollector interface {
+	collector
+	needsMedia() bool
+}
+
+// slowCollector is a collector too expensive to run on every scrape,
+// it runs at most once per defaultInterval unless configured otherwise
+type slowCollector interface {
+	collector
+	defaultInterval() time.Duration
+}
+
+// freeboxCollector is the prometheus.Collector of the exporter: it runs
+// every collector concurrently each time Prometheus scrapes, within
+// a deadline of timeout
+type freeboxCollector struct {
+	client     *client
+	timeout    time.Duration
+	collectors map[string]collector
+	media      map[string]string // WAN media of the line collectors
+	detection  bool              // whether the WAN media is read on each scrape
+	drop       map[string]bool   // labels removed from every metric
+}
+
+func newFreeboxCollector(c *client, cfg *config) *freeboxCollector {
+	collectors := map[string]collector{}
+	media := map[string]string{}
+	detection := false
+	for name, factory := range collectorFactories {
+		if !cfg.enabled(name) {
+			continue
+		}
+		collectors[name] = factory(cfg)
+
+		// the line collectors run on their WAN media unless forced
+		if line, ok := collectors[name].(lineCollector); ok && cfg.Collectors[name].Enabled == nil {
+			media[name] = line.media()
+			detection = true
+		}
+		if m, ok := collectors[name].(mediaCollector); ok && m.needsMedia() {
+			detection = true
+		}
+
+		settings := cfg.Collectors[name]
+		if slow, ok := collectors[name].(slowCollector); ok && settings.Interval == 0 {
+			settings.Interval = slow.defaultInterval()
+		}
+
+		collectors[name] = &resetCollector{collector: collectors[name]}
+		if settings.Interval > 0 || settings.Timeout > 0 {
+			collectors[name] = &scheduledCollector{
+				collector: collectors[name],
+				interval:  settings.Interval,
+				timeout:   settings.Timeout,
+			}
+		}
+		if cfg.StaleGrace > 0 {
+			collectors[name] = &graceCollector{
+				collector: collectors[name],
+				grace:     cfg.StaleGrace,
+			}
+		}
+	}
+
+	drop := map[string]bool{}
+	for _, name := range cfg.Labels.Drop {
+		drop[name] = true
+	}
+
+	return &freeboxCollector{
+		client:     c,
+		timeout:    cfg.Timeout,
+		collectors: collectors,
+		media:      media,
+		detection:  detection,
+		drop:       drop,
+	}
+}
+
+// Describe implements prometheus.Collector, the metrics depend on
+// what the Freebox reports so the collector is left unchecked
+func (f *freeboxCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (f *freeboxCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, lanBrowserKey{}, &lanBrowser{})
+
+	if len(f.drop) > 0 {
+		relabeled := make(chan prometheus.Metric)
+		done := make(chan struct{})
+		go func(ch chan<- prometheus.Metric) {
+			for m := range relabeled {
+				ch <- droppedLabelsMetric{Metric: m, drop: f.drop}
+			}
+			close(done)
+		}(ch)
+		defer func() {
+			close(relabeled)
+			<-done
+		}()
+		ch = relabeled
+	}
+
+	ctx, collectors := f.detect(ctx)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(collectors))
+	for name, c := range collectors {
+		go func(name string, c collector) {
+			defer wg.Done()
+			execute(ctx, name, c, f.client, ch)
+		}(name, c)
+	}
+	wg.Wait()
+
+	v := f.client.version
+	gauge(ch, apiInfoDesc, 1, v.BoxModel, v.BoxModelName, v.DeviceType, v.APIVersion)
+}
+
+// detect returns the collectors to run, leaving out the line collectors
+// of another WAN media than the one of the connection, and the context
+// of the scrape holding that media
+func (f *freeboxCollector) detect(ctx context.Context) (context.Context, map[string]collector) {
+	if !f.detection {
+		return ctx, f.collectors
+	}
+
+	status, err := getConnection(ctx, f.client)
+	if err != nil {
+		log.Printf("An error occured while detecting the WAN media, running every line collector: %v", err)
+		return ctx, f.collectors
+	}
+	media := wanMedia(status.Media)
+
+	collectors := map[string]collector{}
+	for name, c := range f.collectors {
+		if m, ok := f.media[name]; ok && m != media {
+			continue
+		}
+		collectors[name] = c
+	}
+	return context.WithValue(ctx, wanMediaKey{}, media), collectors
+}
+
+type wanMediaKey struct{}
+
+// wanMediaOf returns the WAN media detected for the scrape of ctx, empty
+// when unknown
+func wanMediaOf(ctx context.Context) string {
+	media, _ := ctx.Value(wanMediaKey{}).(string)
+	return media
+}
+
+// lanBrowser is the LAN browser as read once per scrape, the lan and
+// switch collectors both need it
+type lanBrowser struct {
+	once   sync.Once
+	ifaces []lanInterface
+	hosts  map[string][]lanHost // by interface
+	err    error
+}
+
+type lanBrowserKey struct{}
+
+// browseLan returns the interfaces of the LAN browser and their hosts,
+// fetching them only once for the scrape of ctx
+func browseLan(ctx context.Context, c *client) ([]lanInterface, map[string][]lanHost, error) {
+	b, ok := ctx.Value(lanBrowserKey{}).(*lanBrowser)
+	if !ok {
+		b = &lanBrowser{}
+	}
+	b.once.Do(func() {
+		b.hosts = map[string][]lanHost{}
+		b.ifaces, b.err = getLanInterfaces(ctx, c)
+		for _, iface := range b.ifaces {
+			if b.err != nil {
+				return
+			}
+			b.hosts[iface.Name], b.err = getLanHosts(ctx, c, iface.Name)
+		}
+	})
+	return b.ifaces, b.hosts, b.err
+}
+
+// wanMedia names the media of the connection after the line collectors
+func wanMedia(media string) string {
+	switch media {
+	case "backup_4g":
+		return "lte"
+	}
+	return media
+}
+
+// execute runs a single collector and reports its duration and success
+func execute(ctx context.Context, name string, c collector, cl *client, ch chan<- prometheus.Metric) {
+	begin := time.Now()
+	err := c.update(ctx, cl, ch)
+	duration := time.Since(begin)
+
+	success := 1.0
+	if err != nil {
+		log.Printf("An error occured with %s metrics: %v", name, err)
+		success = 0
+	}
+
+	gauge(ch, scrapeDurationDesc, duration.Seconds(), name)
+	gauge(ch, scrapeSuccessDesc, success, name)
+}
+
+// scheduledCollector runs a collector with its own deadline and, when
+// interval is set, serves its last metrics until they get older than
+// interval
+type scheduledCollector struct {
+	collector
+	interval time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	metrics []prometheus.Metric
+}
+
+func (s *scheduledCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	if s.interval <= 0 {
+		return s.collector.update(ctx, c, ch)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.last) < s.interval {
+		for _, m := range s.metrics {
+			ch <- m
+		}
+		return nil
+	}
+
+	metrics := []prometheus.Metric{}
+	buf := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range buf {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+	err := s.collector.update(ctx, c, buf)
+	close(buf)
+	<-done
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	if err == nil {
+		s.last = time.Now()
+		s.metrics = metrics
+	}
+	return err
+}
+
+// graceDescs are the families of the LAN hosts, Wi-Fi stations, VPN
+// users and Freeplugs, the only ones kept through the grace period: the
+// labels of the others carry a state which must not linger
+var graceDescs = map[*prometheus.Desc]bool{
+	lanReachableDesc:             true,
+	lanHostInfoDesc:              true,
+	lanHostActiveDesc:            true,
+	lanHostLastActivityDesc:      true,
+	lanHostFirstActivityDesc:     true,
+	lanHostLastReachableDesc:     true,
+	lanHostAddressDesc:           true,
+	wifiStationInfoDesc:          true,
+	wifiSignalDesc:               true,
+	wifiInactiveDesc:             true,
+	wifiConnectionDurationDesc:   true,
+	wifiRXBytesDesc:              true,
+	wifiTXBytesDesc:              true,
+	wifiRXBytesGaugeDesc:         true,
+	wifiTXBytesGaugeDesc:         true,
+	wifiRXRateDesc:               true,
+	wifiTXRateDesc:               true,
+	wifiTXRetriesDesc:            true,
+	wifiTXFailuresDesc:           true,
+	wifiStationBandwidthDesc:     true,
+	wifiStationMCSDesc:           true,
+	wifiStationNSSDesc:           true,
+	wifiStationPhyRateDesc:       true,
+	wifiStationFlagDesc:          true,
+	vpnServerConnectionBytesDesc: true,
+	vpnServerConnectionsListDesc: true,
+	freeplugRxRateDesc:           true,
+	freeplugTxRateDesc:           true,
+	freeplugHasNetworkDesc:       true,
+}
+
+// graceCollector keeps sending the series of graceDescs a collector
+// stopped reporting until they have been missing for grace, so that
+// a flapping host or station does not churn its series
+type graceCollector struct {
+	collector
+	grace time.Duration
+
+	mu   sync.Mutex
+	seen map[string]seenMetric // by series
+}
+
+// seenMetric is the last value of a series and when it was reported
+type seenMetric struct {
+	metric prometheus.Metric
+	last   time.Time
+}
+
+func (g *graceCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen == nil {
+		g.seen = map[string]seenMetric{}
+	}
+
+	now := time.Now()
+	current := map[string]bool{}
+	buf := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range buf {
+			if graceDescs[m.Desc()] {
+				key := seriesKey(m)
+				current[key] = true
+				g.seen[key] = seenMetric{metric: m, last: now}
+			}
+			ch <- m
+		}
+		close(done)
+	}()
+	err := g.collector.update(ctx, c, buf)
+	close(buf)
+	<-done
+
+	// a failed fetch tells nothing about the series which are missing
+	if err != nil {
+		return err
+	}
+
+	for key, s := range g.seen {
+		if current[key] {
+			continue
+		}
+		if now.Sub(s.last) > g.grace {
+			delete(g.seen, key)
+			continue
+		}
+		ch <- s.metric
+	}
+	return nil
+}
+
+// resetCollector tells when the counters of a collector went back to
+// zero, after a reboot of the box, a DSL resync or a station reconnect,
+// by sending <name>_reset_timestamp_seconds next to each counter which
+// has been seen going down
+type resetCollector struct {
+	collector
+	clock func() time.Time // time.Now when nil
+
+	mu       sync.Mutex
+	counters map[string]counterState     // by series
+	descs    map[string]*prometheus.Desc // reset metrics, by counter family
+}
+
+// counterTTL is how long the state of a counter series which is no
+// longer reported, like a departed station, is kept
+const counterTTL = time.Hour
+
+// counterState is the last value of a counter series, its last reset
+// and when it was last reported
+type counterState struct {
+	value float64
+	reset time.Time
+	last  time.Time
+}
+
+// fqNameRE extracts the name of a metric from the String of its Desc
+var fqNameRE = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+func (r *resetCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counters == nil {
+		r.counters = map[string]counterState{}
+		r.descs = map[string]*prometheus.Desc{}
+	}
+
+	now := time.Now()
+	if r.clock != nil {
+		now = r.clock()
+	}
+	resets := []prometheus.Metric{}
+	buf := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range buf {
+			ch <- m
+
+			out := &dto.Metric{}
+			if m.Write(out) != nil || out.Counter == nil {
+				continue
+			}
+			key := seriesKey(m)
+			state, ok := r.counters[key]
+			if ok && out.Counter.GetValue() < state.value {
+				state.reset = now
+			}
+			state.value = out.Counter.GetValue()
+			state.last = now
+			r.counters[key] = state
+
+			if !state.reset.IsZero() {
+				if reset := r.resetMetric(m, out, state.reset); reset != nil {
+					resets = append(resets, reset)
+				}
+			}
+		}
+		close(done)
+	}()
+	err := r.collector.update(ctx, c, buf)
+	close(buf)
+	<-done
+
+	for _, m := range resets {
+		ch <- m
+	}
+
+	// a counter missing from a fetch, like the xDSL errors right after
+	// a resync, keeps its last value for counterTTL so that its reset
+	// is still seen when it comes back lower
+	if err == nil {
+		for key, state := range r.counters {
+			if now.Sub(state.last) > counterTTL {
+				delete(r.counters, key)
+			}
+		}
+	}
+	return err
+}
+
+// resetMetric returns the time of the last reset of the counter m, with
+// the labels of m
+func (r *resetCollector) resetMetric(m prometheus.Metric, out *dto.Metric, reset time.Time) prometheus.Metric {
+	match := fqNameRE.FindStringSubmatch(m.Desc().String())
+	if match == nil {
+		return nil
+	}
+
+	names := []string{}
+	values := []string{}
+	for _, l := range out.GetLabel() {
+		names = append(names, l.GetName())
+		values = append(values, l.GetValue())
+	}
+
+	name := strings.TrimSuffix(match[1], "_total") + "_reset_timestamp_seconds"
+	key := name + "," + strings.Join(names, ",")
+	desc, ok := r.descs[key]
+	if !ok {
+		desc = prometheus.NewDesc(name, "Last time "+match[1]+" was seen going back to zero", names, nil)
+		r.descs[key] = desc
+	}
+
+	metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, float64(reset.Unix()), values...)
+	if err != nil {
+		return nil
+	}
+	return metric
+}
+
+// seriesKey identifies the series of m by its name and its labels
+func seriesKey(m prometheus.Metric) string {
+	out := &dto.Metric{}
+	if err := m.Write(out); err != nil {
+		return m.Desc().String()
+	}
+
+	key := m.Desc().String()
+	for _, l := range out.GetLabel() {
+		key += "," + l.GetName() + "=" + strconv.Quote(l.GetValue())
+	}
+	return key
+}
+
+// droppedLabelsMetric hides the labels dropped by the label policy
+type droppedLabelsMetric struct {
+	prometheus.Metric
+	drop map[string]bool
+}
+
+func (m droppedLabelsMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	labels := out.Label[:0]
+	for _, l := range out.Label {
+		if !m.drop[l.GetName()] {
+			labels = append(labels, l)
+		}
+	}
+	out.Label = labels
+	return nil
+}
+
+// gauge sends a gauge sample of desc
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labelValues ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+}
+
+// counter sends a counter sample of desc
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labelValues ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labelValues...)
+}
+
+// cumulative sends a value which only grows until it is reset as a
+// counter, or as a gauge like the exporter used to with -compat.gauges
+func cumulative(ch chan<- prometheus.Metric, compat bool, desc *prometheus.Desc, value float64, labelValues ...string) {
+	if compat {
+		gauge(ch, desc, value, labelValues...)
+		return
+	}
+	counter(ch, desc, value, labelValues...)
+}
+
+// connectionStates are the states a WAN connection goes through
+var connectionStates = []string{"up", "going_up", "down", "going_down"}
+
+type connectionCollector struct{}
+
+func (connectionCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	result, err := getConnection(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range connectionStates {
+		gauge(ch, connectionStateDesc, bool2float(result.State == state), state)
+	}
+	gauge(ch, connectionInfoDesc, 1, result.Type, result.Media, result.IPv4, result.IPv6)
+
+	gauge(ch, connectionBandwidthDesc, float64(result.BandwidthUp), "up")
+	gauge(ch, connectionBandwidthDesc, float64(result.BandwidthDown), "down")
+	gauge(ch, connectionRateDesc, float64(result.RateUp), "up")
+	gauge(ch, connectionRateDesc, float64(result.RateDown), "down")
+	counter(ch, connectionBytesDesc, float64(result.BytesUp), "up")
+	counter(ch, connectionBytesDesc, float64(result.BytesDown), "down")
+
+	return nil
+}
+
+// ddnsProviders are the DynDNS providers known by the Freebox
+var ddnsProviders = []string{"ovh", "dyndns", "noip"}
+
+type connectionConfigCollector struct{}
+
+func (connectionConfigCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	config, err := getConnectionConfig(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	gauge(ch, connectionConfigInfoDesc, 1,
+		strconv.FormatBool(config.Ping),
+		strconv.FormatBool(config.Wol),
+		strconv.FormatBool(config.Adblock),
+		strconv.FormatBool(config.RemoteAccess),
+		strconv.Itoa(config.RemoteAccessPort),
+		strconv.FormatBool(config.APIRemoteAccess),
+		strconv.FormatBool(config.AllowTokenRequest),
+		strconv.FormatBool(config.IsSecurePass))
+
+	ipv6, err := getConnectionIPv6Config(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	gauge(ch, connectionIPv6InfoDesc, 1,
+		strconv.FormatBool(ipv6.IPv6Enabled), strconv.FormatBool(ipv6.IPv6Firewall), ipv6.IPv6LL)
+	for _, d := range ipv6.Delegations {
+		gauge(ch, connectionIPv6DelegationInfoDesc, 1, d.Prefix, d.NextHop)
+	}
+
+	for _, provider := range ddnsProviders {
+		status, err := getDdnsStatus(ctx, c, provider)
+		if err == errNotFound {
+			// older Freeboxes do not know every provider
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		gauge(ch, ddnsStatusInfoDesc, 1, provider, status.Status)
+		if status.LastRefresh > 0 {
+			gauge(ch, ddnsLastRefreshDesc, float64(status.LastRefresh), provider)
+		}
+		if status.LastError > 0 {
+			gauge(ch, ddnsLastErrorDesc, float64(status.LastError), provider)
+		}
+		if status.NextRepeat > 0 {
+			gauge(ch, ddnsNextRetryDesc, float64(status.NextRepeat), provider)
+		}
+	}
+
+	return nil
+}
+
+// dhcpCollector sends the configuration, the pool usage and the leases
+// of the DHCP and DHCPv6 servers
+type dhcpCollector struct{}
+
+func (dhcpCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	config, err := getDhcpConfig(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	gauge(ch, dhcpEnabledDesc, bool2float(config.Enabled), "ipv4")
+	gauge(ch, dhcpConfigInfoDesc, 1,
+		config.Gateway,
+		config.Netmask,
+		config.IPRangeStart,
+		config.IPRangeEnd,
+		strconv.FormatBool(config.StickyAssign),
+		strconv.FormatBool(config.AlwaysBroadcast),
+		strings.Join(config.DNS, ","))
+
+	leases, err := updateDhcpLeases(ctx, c, ch, "dhcp", "ipv4")
+	if err != nil {
+		return err
+	}
+
+	// the static leases may be outside of the dynamic range, only the
+	// addresses within it count towards its exhaustion
+	start, end := ipv4ToInt(config.IPRangeStart), ipv4ToInt(config.IPRangeEnd)
+	if start > 0 && end >= start {
+		used := 0
+		for _, l := range leases {
+			if ip := ipv4ToInt(l.IP); ip >= start && ip <= end {
+				used++
+			}
+		}
+		size := end - start + 1
+		gauge(ch, dhcpPoolSizeDesc, float64(size))
+		gauge(ch, dhcpPoolLeasesDesc, float64(used))
+		gauge(ch, dhcpPoolUtilizationDesc, float64(used)/float64(size))
+	}
+
+	config6, err := getDhcpv6Config(ctx, c)
+	if err == errNotFound {
+		// older Freeboxes have no DHCPv6 server
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	gauge(ch, dhcpEnabledDesc, bool2float(config6.Enabled), "ipv6")
+	_, err = updateDhcpLeases(ctx, c, ch, "dhcpv6", "ipv6")
+	return err
+}
+
+// updateDhcpLeases sends the static and dynamic leases of the DHCP
+// server of prefix, and returns the dynamic ones
+func updateDhcpLeases(ctx context.Context, c *client, ch chan<- prometheus.Metric, prefix, family string) ([]dhcpDynamicLease, error) {
+	static, err := getDhcpStaticLeases(ctx, c, prefix)
+	if err != nil && err != errNotFound {
+		return nil, err
+	}
+	gauge(ch, dhcpLeasesDesc, float64(len(static)), family, "static")
+	for _, l := range static {
+		gauge(ch, dhcpStaticLeaseOnlineDesc, bool2float(l.Host.Reachable), family, l.Mac, l.IP, l.Hostname)
+	}
+
+	dynamic, err := getDhcpDynamicLeases(ctx, c, prefix)
+	if err != nil && err != errNotFound {
+		return nil, err
+	}
+	gauge(ch, dhcpLeasesDesc, float64(len(dynamic)), family, "dynamic")
+	for _, l := range dynamic {
+		gauge(ch, dhcpLeaseExpiryDesc, float64(l.LeaseRemaining), family, l.Mac, l.IP, l.Hostname)
+	}
+	return dynamic, nil
+}
+
+// ipv4ToInt returns the IPv4 address ip as an integer, 0 when it is not
+// an IPv4 address
+func ipv4ToInt(ip string) int64 {
+	v4 := net.ParseIP(ip).To4()
+	if v4 == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint32(v4))
+}
+
+// firewallCollector sends the port forwardings, the incoming ports, the
+// DMZ and the UPnP IGD redirections, that is every port of the WAN
+// reaching the LAN
+type firewallCollector struct{}
+
+func (firewallCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	redirs, err := getFwRedirs(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	ports := 0
+	for _, r := range redirs {
+		if !r.Enabled {
+			continue
+		}
+		end := r.WanPortEnd
+		if end < r.WanPortStart {
+			end = r.WanPortStart
+		}
+		ports += end - r.WanPortStart + 1
+		gauge(ch, fwRedirInfoDesc, 1,
+			strconv.Itoa(r.ID),
+			r.IPProto,
+			strconv.Itoa(r.WanPortStart),
+			strconv.Itoa(end),
+			r.LanIP,
+			strconv.Itoa(r.LanPort),
+			r.SrcIP,
+			r.Comment)
+	}
+	gauge(ch, fwWanPortsDesc, float64(ports), "redir")
+
+	incoming, err := getFwIncoming(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	ports = 0
+	for _, i := range incoming {
+		if !i.Enabled {
+			continue
+		}
+		ports++
+		gauge(ch, fwIncomingInfoDesc, 1, i.ID, i.Type, strconv.Itoa(i.InPort))
+	}
+	gauge(ch, fwWanPortsDesc, float64(ports), "incoming")
+
+	dmz, err := getFwDmz(ctx, c)
+	if err != nil {
+		return err
+	}
+	gauge(ch, fwDmzEnabledDesc, bool2float(dmz.Enabled), dmz.IP)
+
+	upnp, err := getUpnpRedirs(ctx, c)
+	if err == errNotFound {
+		// the UPnP IGD API is missing when UPnP is not available
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ports = 0
+	for _, r := range upnp {
+		if !r.Enabled {
+			continue
+		}
+		ports++
+		extPort := strconv.Itoa(r.ExtPort)
+		gauge(ch, upnpRedirInfoDesc, 1, r.Proto, extPort, r.ExtSrcIP, r.IntIP, strconv.Itoa(r.IntPort), r.Desc)
+		gauge(ch, upnpRedirRemainingDesc, float64(r.Remaining), r.Proto, extPort)
+	}
+	gauge(ch, upnpRedirsDesc, float64(ports))
+	gauge(ch, fwWanPortsDesc, float64(ports), "upnp")
+
+	return nil
+}
+
+// ftthCollector sends the state of the SFP and of the fiber link,
+// /connection/ftth/ reports no PON alarm: a loss of signal or of power
+// supply only shows in sfp_has_signal and sfp_alim_ok
+type ftthCollector struct{}
+
+func (ftthCollector) media() string { return "ftth" }
+
+func (ftthCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	result, err := getConnectionFtth(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	gauge(ch, connectionFtthSfpPresentDesc, bool2float(result.SfpPresent))
+	if !result.SfpPresent {
+		return nil
+	}
+
+	gauge(ch, connectionFtthSfpAlimOkDesc, bool2float(result.SfpAlimOk))
+	gauge(ch, connectionFtthSfpHasPowerReportDesc, bool2float(result.SfpHasPowerReport))
+	gauge(ch, connectionFtthSfpHasSignalDesc, bool2float(result.SfpHasSignal))
+	gauge(ch, connectionFtthLinkDesc, bool2float(result.Link))
+	gauge(ch, connectionFtthSfpInfoDesc, 1, result.SfpModel, result.SfpVendor, result.SfpSerial)
+
+	// the optical power is meaningless when the SFP does not report it
+	if result.SfpHasPowerReport {
+		gauge(ch, connectionFtthRxPowerDesc, float64(result.SfpPwrRx)/100)
+		gauge(ch, connectionFtthTxPowerDesc, float64(result.SfpPwrTx)/100)
+	}
+
+	return nil
+}
+
+type xdslCollector struct {
+	compat bool // send the uptime and the error counts as gauges
+}
+
+func (xdslCollector) media() string { return "xdsl" }
+
+func (x xdslCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	// connectionXdsl metrics
+	result, err := getConnectionXdsl(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	status := result.Status
+	down := result.Down
+	up := result.Up
+
+	cumulative(ch, x.compat, connectionXdslStatusUptimeDesc, float64(status.Uptime),
+		status.Status, status.Protocol, status.Modulation)
+
+	gauge(ch, connectionXdslDownAttnDesc, float64(down.Attn10)/10)
+	gauge(ch, connectionXdslUpAttnDesc, float64(up.Attn10)/10)
+
+	// XXX: sometimes the Freebox is reporting zero as SNR which
+	// does not make sense so we don't log these
+	if down.Snr10 > 0 {
+		gauge(ch, connectionXdslDownSnrDesc, float64(down.Snr10)/10)
+	}
+	if up.Snr10 > 0 {
+		gauge(ch, connectionXdslUpSnrDesc, float64(up.Snr10)/10)
+	}
+
+	gauge(ch, connectionXdslNitroDesc, bool2float(down.Nitro), "down")
+	gauge(ch, connectionXdslNitroDesc, bool2float(up.Nitro), "up")
+
+	gauge(ch, connectionXdslGinpDesc, bool2float(down.Ginp), "down", "enabled")
+	gauge(ch, connectionXdslGinpDesc, bool2float(up.Ginp), "up", "enabled")
+
+	logFields(ch, &result, connectionXdslGinpDesc, prometheus.GaugeValue, true,
+		[]string{"rtx_tx", "rtx_c", "rtx_uc"})
+
+	errorsType := prometheus.CounterValue
+	if x.compat {
+		errorsType = prometheus.GaugeValue
+	}
+	logFields(ch, &result, connectionXdslErrorDesc, errorsType, false,
+		[]string{"crc", "es", "fec", "hec", "ses"})
+
+	return nil
+}
+
+type freeplugCollector struct{}
+
+func (freeplugCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	freeplugStats, err := getFreeplug(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, freeplugNetwork := range freeplugStats {
+		for _, freeplugMember := range freeplugNetwork.Members {
+			gauge(ch, freeplugHasNetworkDesc, bool2float(freeplugMember.HasNetwork), freeplugMember.ID)
+
+			Mb := 1e6
+			rxRate := float64(freeplugMember.RxRate) * Mb
+			txRate := float64(freeplugMember.TxRate) * Mb
+
+			if rxRate >= 0 { // -1 if not unavailable
+				gauge(ch, freeplugRxRateDesc, rxRate, freeplugMember.ID)
+			}
+
+			if txRate >= 0 { // -1 if not unavailable
+				gauge(ch, freeplugTxRateDesc, txRate, freeplugMember.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rrdLines names the line collector of the RRD databases holding line
+// metrics, such a database is only read when that collector would run
+var rrdLines = map[string]string{"dsl": "xdsl"}
+
+// rrdCollector sends the newest point of the RRD fields of the
+// configuration, with its own timestamp
+type rrdCollector struct {
+	dbs     []string                    // in the order of the configuration
+	metrics map[string][]rrdMetric      // by db
+	media   map[string]string           // WAN media of the line databases
+	descs   map[string]*prometheus.Desc // by name
+}
+
+func newRRDCollector(cfg *config) *rrdCollector {
+	r := &rrdCollector{
+		metrics: map[string][]rrdMetric{},
+		media:   map[string]string{},
+		descs:   map[string]*prometheus.Desc{},
+	}
+	for _, m := range cfg.RRD {
+		line, ok := rrdLines[m.DB]
+		if ok && !cfg.enabled(line) {
+			continue
+		}
+		if ok && cfg.Collectors[line].Enabled == nil {
+			r.media[m.DB] = line
+		}
+
+		if _, ok := r.metrics[m.DB]; !ok {
+			r.dbs = append(r.dbs, m.DB)
+		}
+		r.metrics[m.DB] = append(r.metrics[m.DB], m)
+
+		name := m.fullName()
+		if _, ok := r.descs[name]; !ok {
+			r.descs[name] = prometheus.NewDesc(name, m.Help, m.labelNames(), nil)
+		}
+	}
+	return r
+}
+
+func (r *rrdCollector) needsMedia() bool { return len(r.media) > 0 }
+
+func (r *rrdCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	var err error
+	read, failed := 0, 0
+	media := wanMediaOf(ctx)
+	for _, db := range r.dbs {
+		if m, ok := r.media[db]; ok && media != "" && m != media {
+			continue
+		}
+		read++
+
+		fields := []string{}
+		for _, m := range r.metrics[db] {
+			fields = append(fields, m.Field)
+		}
+
+		point, e := getRRD(ctx, c, db, fields)
+		if e != nil {
+			log.Printf("An error occured with the %s RRD database: %v", db, e)
+			err = e
+			failed++
+			continue
+		}
+		if len(point) == 0 {
+			continue
+		}
+
+		t := time.Unix(point["time"], 0)
+		for _, m := range r.metrics[db] {
+			raw, ok := point[m.Field]
+			if !ok {
+				continue
+			}
+
+			labelValues := []string{}
+			for _, name := range m.labelNames() {
+				labelValues = append(labelValues, m.Labels[name])
+			}
+			metric, e := prometheus.NewConstMetric(r.descs[m.fullName()], m.valueType(), m.value(raw), labelValues...)
+			if e != nil {
+				log.Printf("An error occured with the %s field of the %s RRD database: %v", m.Field, db, e)
+				continue
+			}
+			ch <- prometheus.NewMetricWithTimestamp(t, metric)
+		}
+	}
+
+	// the collector only fails when no database could be read
+	if failed > 0 && failed == read {
+		return err
+	}
+	return nil
+}
+
+// switchCollector sends the status, the counters and the hosts of every
+// port, the rates of the switch RRD are sent by the rrd collector
+type switchCollector struct{}
+
+func (switchCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	ports, err := getSwitchStatus(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	// the LAN browser names the hosts of pub, the network behind the
+	// switch, better than the switch, which only knows the names
+	// announced over DHCP
+	hostnames := map[string]string{}
+	if _, hosts, err := browseLan(ctx, c); err == nil {
+		for _, h := range hosts["pub"] {
+			if h.L2Ident.Type == "mac_address" && h.PrimaryName != "" {
+				hostnames[strings.ToUpper(h.L2Ident.ID)] = h.PrimaryName
+			}
+		}
+	}
+
+	for _, p := range ports {
+		stats, err := getSwitchPortStats(ctx, c, p.ID)
+		if err != nil {
+			return err
+		}
+
+		port := strconv.Itoa(p.ID)
+		gauge(ch, switchPortLinkDesc, bool2float(p.Link == "up"), port)
+		if speed, err := strconv.ParseFloat(p.Speed, 64); err == nil {
+			gauge(ch, switchPortSpeedDesc, speed*1e6, port)
+		}
+		gauge(ch, switchPortInfoDesc, 1, port, p.Mode, p.Duplex)
+
+		counter(ch, switchPortBytesDesc, float64(stats.RxGoodBytes), port, "rx")
+		counter(ch, switchPortBytesDesc, float64(stats.TxBytes), port, "tx")
+		counter(ch, switchPortPacketsDesc, float64(stats.RxGoodPackets), port, "rx")
+		counter(ch, switchPortPacketsDesc, float64(stats.TxPackets), port, "tx")
+		counter(ch, switchPortErrorsDesc, float64(stats.RxErrPackets), port, "rx")
+		counter(ch, switchPortErrorsDesc, float64(stats.TxFcs), port, "tx")
+		counter(ch, switchPortCollisionsDesc, float64(stats.TxCollisions), port)
+
+		config, err := getSwitchPort(ctx, c, p.ID)
+		if err != nil {
+			return err
+		}
+		for _, m := range config.MacList {
+			hostname, ok := hostnames[strings.ToUpper(m.Mac)]
+			if !ok {
+				hostname = m.Hostname
+			}
+			gauge(ch, switchPortHostInfoDesc, 1, port, m.Mac, hostname)
+		}
+	}
+	return nil
+}
+
+// lanCollector sends the hosts of every interface of the LAN browser,
+// the Wi-Fi guest network included
+type lanCollector struct{}
+
+func (lanCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	ifaces, browser, err := browseLan(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	types := map[string]int{}
+	reachable := map[[3]string]bool{} // by name, vendor and ip
+	for _, iface := range ifaces {
+		hosts := browser[iface.Name]
+		gauge(ch, lanInterfaceHostsDesc, float64(len(hosts)), iface.Name)
+
+		for _, v := range hosts {
+			// the former metric only covers pub, where two hosts may
+			// share a name without an address
+			if iface.Name == "pub" {
+				var ip string
+				if len(v.L3c) > 0 {
+					ip = v.L3c[0].Addr
+				}
+				key := [3]string{v.PrimaryName, v.Vendor_name, ip}
+				reachable[key] = reachable[key] || v.Reachable
+			}
+
+			types[v.HostType]++
+			mac := v.L2Ident.ID
+			gauge(ch, lanHostInfoDesc, 1, iface.Name, mac, v.PrimaryName, v.Vendor_name, v.HostType)
+			gauge(ch, lanHostActiveDesc, bool2float(v.Active), iface.Name, mac)
+			if v.LastActivity > 0 {
+				gauge(ch, lanHostLastActivityDesc, float64(v.LastActivity), iface.Name, mac)
+			}
+			if v.FirstActivity > 0 {
+				gauge(ch, lanHostFirstActivityDesc, float64(v.FirstActivity), iface.Name, mac)
+			}
+			if v.LastTimeReachable > 0 {
+				gauge(ch, lanHostLastReachableDesc, float64(v.LastTimeReachable), iface.Name, mac)
+			}
+			for _, a := range v.L3c {
+				gauge(ch, lanHostAddressDesc, bool2float(a.Active), iface.Name, mac, a.Af, a.Addr)
+			}
+		}
+	}
+
+	for key, r := range reachable {
+		gauge(ch, lanReachableDesc, bool2float(r), key[0], key[1], key[2])
+	}
+	for hostType, count := range types {
+		gauge(ch, lanHostTypeHostsDesc, float64(count), hostType)
+	}
+	return nil
+}
+
+type systemCollector struct {
+	compat bool // send the uptime as a gauge
+}
+
+func (s systemCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	systemStats, err := getSystem(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	gauge(ch, systemTempDesc, float64(systemStats.TempCpub), "Température CPU B")
+	gauge(ch, systemTempDesc, float64(systemStats.TempCpum), "Température CPU M")
+	gauge(ch, systemTempDesc, float64(systemStats.TempSW), "Température Switch")
+	gauge(ch, systemTempDesc, float64(systemStats.TempHDD), "Disque dur")
+	gauge(ch, systemFanDesc, float64(systemStats.FanRPM), "Ventilateur 1")
+
+	cumulative(ch, s.compat, systemUptimeDesc, float64(systemStats.UptimeVal), systemStats.FirmwareVersion)
+
+	return nil
+}
+
+// wifiApStates are the states the radio of an access point goes through
+var wifiApStates = []string{
+	"scanning", "no_param", "bad_param", "disabled", "disabled_planning", "no_active_bss",
+	"starting", "acs", "ht_scan", "dfs", "active", "failed",
+}
+
+// wifiCollector sends the radios, their BSS and their stations, on every
+// band the box has (2.4, 5 and 6 GHz)
+type wifiCollector struct {
+	compat bool // also send the bytes under their former gauge names
+}
+
+func (w wifiCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	wifiStats, err := getWifi(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	names := map[int]string{}
+	for _, accessPoint := range wifiStats {
+		names[accessPoint.ID] = accessPoint.Name
+		status := accessPoint.Status
+
+		width := status.ChannelWidth
+		if width == "" {
+			width = accessPoint.Config.ChannelWidth
+		}
+		gauge(ch, wifiApInfoDesc, 1, accessPoint.Name, accessPoint.Config.Band, width)
+		for _, state := range wifiApStates {
+			gauge(ch, wifiApStateDesc, bool2float(status.State == state), accessPoint.Name, state)
+		}
+		gauge(ch, wifiApChannelDesc, float64(status.PrimaryChannel), accessPoint.Name, "primary")
+		if status.SecondaryChannel > 0 {
+			gauge(ch, wifiApChannelDesc, float64(status.SecondaryChannel), accessPoint.Name, "secondary")
+		}
+		gauge(ch, wifiApDfsEnabledDesc, bool2float(accessPoint.Config.DfsEnabled && !status.DfsDisabled), accessPoint.Name)
+		gauge(ch, wifiApDfsCacRemainingDesc, float64(status.DfsCacRemainingTime), accessPoint.Name)
+	}
+
+	bss, err := getWifiBss(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bss {
+		params := b.params()
+		gauge(ch, wifiBssInfoDesc, 1, b.ID, names[b.PhyID], params.SSID, params.Encryption, strconv.FormatBool(params.HideSSID))
+		gauge(ch, wifiBssEnabledDesc, bool2float(params.Enabled), b.ID)
+		gauge(ch, wifiBssActiveDesc, bool2float(b.Status.State == "active"), b.ID)
+		gauge(ch, wifiBssStationsDesc, float64(b.Status.AuthorizedStaCount), b.ID, "true")
+		gauge(ch, wifiBssStationsDesc, float64(b.Status.StaCount-b.Status.AuthorizedStaCount), b.ID, "false")
+	}
+
+	for _, accessPoint := range wifiStats {
+		wifiStationsStats, err := getWifiStations(ctx, c, accessPoint.ID)
+		if err != nil {
+			return err
+		}
+		for _, station := range wifiStationsStats {
+			labels := []string{accessPoint.Name, station.MAC}
+			gauge(ch, wifiStationInfoDesc, 1, accessPoint.Name, station.MAC, station.Hostname,
+				accessPoint.Config.Band, station.Flags.standard())
+			gauge(ch, wifiSignalDesc, float64(station.Signal), labels...)
+			gauge(ch, wifiInactiveDesc, float64(station.Inactive), labels...)
+			gauge(ch, wifiConnectionDurationDesc, float64(station.ConnectionDuration), labels...)
+			counter(ch, wifiRXBytesDesc, float64(station.RXBytes), labels...)
+			counter(ch, wifiTXBytesDesc, float64(station.TXBytes), labels...)
+			if w.compat {
+				gauge(ch, wifiRXBytesGaugeDesc, float64(station.RXBytes), labels...)
+				gauge(ch, wifiTXBytesGaugeDesc, float64(station.TXBytes), labels...)
+			}
+			gauge(ch, wifiRXRateDesc, float64(station.RXRate), labels...)
+			gauge(ch, wifiTXRateDesc, float64(station.TXRate), labels...)
+			counter(ch, wifiTXRetriesDesc, float64(station.TXRetries), labels...)
+			counter(ch, wifiTXFailuresDesc, float64(station.TXFailed), labels...)
+
+			for direction, rate := range map[string]wifiStationRate{"rx": station.LastRX, "tx": station.LastTX} {
+				if width, err := strconv.Atoi(rate.Width); err == nil {
+					gauge(ch, wifiStationBandwidthDesc, float64(width), accessPoint.Name, station.MAC, direction)
+				}
+				gauge(ch, wifiStationMCSDesc, float64(rate.mcs()), accessPoint.Name, station.MAC, direction)
+				gauge(ch, wifiStationNSSDesc, float64(rate.NSS), accessPoint.Name, station.MAC, direction)
+				gauge(ch, wifiStationPhyRateDesc, float64(rate.Bitrate)*100000, accessPoint.Name, station.MAC, direction)
+			}
+
+			gauge(ch, wifiStationFlagDesc, bool2float(station.Flags.VHT), accessPoint.Name, station.MAC, "vht")
+			gauge(ch, wifiStationFlagDesc, bool2float(station.Flags.HE), accessPoint.Name, station.MAC, "he")
+			gauge(ch, wifiStationFlagDesc, bool2float(station.Flags.Powersave), accessPoint.Name, station.MAC, "powersave")
+			gauge(ch, wifiStationFlagDesc, bool2float(station.Flags.Authorized), accessPoint.Name, station.MAC, "authorized")
+		}
+	}
+
+	return nil
+}
+
+// wifiSurveyCollector sends the use of the channels and the neighbouring
+// BSS each access point hears, which needs a scan of the radio
+type wifiSurveyCollector struct{}
+
+func (wifiSurveyCollector) defaultInterval() time.Duration { return 10 * time.Minute }
+
+func (wifiSurveyCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	accessPoints, err := getWifi(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, accessPoint := range accessPoints {
+		usage, err := getWifiChannelUsage(ctx, c, accessPoint.ID)
+		if err != nil {
+			return err
+		}
+		for _, u := range usage {
+			channel := strconv.Itoa(u.Channel)
+			gauge(ch, wifiChannelBusyDesc, float64(u.BusyPercent), accessPoint.Name, u.Band, channel, "total")
+			gauge(ch, wifiChannelBusyDesc, float64(u.RxBusyPercent), accessPoint.Name, u.Band, channel, "rx")
+			gauge(ch, wifiChannelBusyDesc, float64(u.TxPercent), accessPoint.Name, u.Band, channel, "tx")
+			gauge(ch, wifiChannelNoiseDesc, float64(u.NoiseLevel), accessPoint.Name, u.Band, channel)
+		}
+
+		neighbors, err := getWifiNeighbors(ctx, c, accessPoint.ID)
+		if err != nil {
+			return err
+		}
+		for _, n := range neighbors {
+			gauge(ch, wifiNeighborSignalDesc, float64(n.Signal),
+				accessPoint.Name, n.BSSID, n.SSID, n.Band, strconv.Itoa(n.Channel), n.ChannelWidth)
+		}
+	}
+
+	return nil
+}
+
+type vpnCollector struct {
+	compat bool // also send the bytes in vpn_server_connections_list
+}
+
+func (v vpnCollector) update(ctx context.Context, c *client, ch chan<- prometheus.Metric) error {
+	getVpnServerResult, err := getVpnServer(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, connection := range getVpnServerResult {
+		counter(ch, vpnServerConnectionBytesDesc, float64(connection.RxBytes),
+			connection.User, connection.Vpn, connection.SrcIP, connection.LocalIP, "rx")
+		counter(ch, vpnServerConnectionBytesDesc, float64(connection.TxBytes),
+			connection.User, connection.Vpn, connection.SrcIP, connection.LocalIP, "tx")
+
+		if v.compat {
+			gauge(ch, vpnServerConnectionsListDesc, float64(connection.RxBytes),
+				connection.User, connection.Vpn, connection.SrcIP, connection.LocalIP, "rx_bytes")
+			gauge(ch, vpnServerConnectionsListDesc, float64(connection.TxBytes),
+				connection.User, connection.Vpn, connection.SrcIP, connection.LocalIP, "tx_bytes")
+		}
+	}
+
+	return nil
+}
+
+// logFields sends the fields of both directions of result, the zero
+// values are left out when skipZero is set
+func logFields(ch chan<- prometheus.Metric, result interface{}, desc *prometheus.Desc, valueType prometheus.ValueType, skipZero bool, fields []string) {
+	resultReflect := reflect.ValueOf(result)
+
+	for _, direction := range []string{"down", "up"} {
+		for _, field := range fields {
+			value := reflect.Indirect(resultReflect).
+				FieldByName(strcase.ToCamel(direction)).
+				FieldByName(strcase.ToCamel(field))
+
+			if skipZero && value.IsZero() {
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(desc, valueType, float64(value.Int()), direction, field)
+		}
+	}
+}
+
+func bool2float(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,1180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSystemCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, system{
+			FanRPM:          666,
+			TempCpub:        81,
+			TempCpum:        89,
+			TempHDD:         30,
+			TempSW:          54,
+			UptimeVal:       3600,
+			FirmwareVersion: "4.2.0",
+		})
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"system": systemCollector{}},
+	}
+
+	expected := `
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="system"} 1
+# HELP freebox_system_fan_rpm Fan speed reported by system (in RPM)
+# TYPE freebox_system_fan_rpm gauge
+freebox_system_fan_rpm{name="Ventilateur 1"} 666
+# HELP freebox_system_uptime_seconds_total Freebox Server uptime (in seconds)
+# TYPE freebox_system_uptime_seconds_total counter
+freebox_system_uptime_seconds_total{firmware_version="4.2.0"} 3600
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_scrape_collector_success", "freebox_system_fan_rpm", "freebox_system_uptime_seconds_total")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// the dashboards made for the gauges keep working
+	f.collectors["system"] = systemCollector{compat: true}
+	expected = `
+# HELP freebox_system_uptime_seconds_total Freebox Server uptime (in seconds)
+# TYPE freebox_system_uptime_seconds_total gauge
+freebox_system_uptime_seconds_total{firmware_version="4.2.0"} 3600
+`
+	err = testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_system_uptime_seconds_total")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestFreeboxCollectorTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		writeResult(w, system{})
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    50 * time.Millisecond,
+		collectors: map[string]collector{"system": systemCollector{}},
+	}
+
+	expected := `
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="system"} 0
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_scrape_collector_success", "freebox_system_fan_rpm")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+// countingCollector counts how many times the Freebox has been queried
+type countingCollector struct {
+	calls int
+}
+
+func (c *countingCollector) update(ctx context.Context, cl *client, ch chan<- prometheus.Metric) error {
+	c.calls++
+	gauge(ch, systemFanDesc, float64(c.calls), "Ventilateur 1")
+	return nil
+}
+
+func TestScheduledCollector(t *testing.T) {
+	counting := &countingCollector{}
+	f := &freeboxCollector{
+		client:  newTestClient("http://127.0.0.1:0"),
+		timeout: time.Second,
+		collectors: map[string]collector{"system": &scheduledCollector{
+			collector: counting,
+			interval:  time.Hour,
+		}},
+	}
+
+	expected := `
+# HELP freebox_system_fan_rpm Fan speed reported by system (in RPM)
+# TYPE freebox_system_fan_rpm gauge
+freebox_system_fan_rpm{name="Ventilateur 1"} 1
+`
+	for i := 0; i < 2; i++ {
+		err := testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_system_fan_rpm")
+		if err != nil {
+			t.Error("Expected no err, but got", err)
+		}
+	}
+
+	if counting.calls != 1 {
+		t.Error("Expected 1, but got", counting.calls)
+	}
+}
+
+// uptimeCollector reports the uptime it is given, nothing when it is
+// negative
+type uptimeCollector struct {
+	uptime float64
+}
+
+func (c *uptimeCollector) update(ctx context.Context, cl *client, ch chan<- prometheus.Metric) error {
+	if c.uptime >= 0 {
+		counter(ch, systemUptimeDesc, c.uptime, "4.2.0")
+	}
+	return nil
+}
+
+func TestResetCollector(t *testing.T) {
+	uptime := &uptimeCollector{uptime: 3600}
+	now := time.Unix(1600000000, 0)
+	f := &freeboxCollector{
+		client:  newTestClient("http://127.0.0.1:0"),
+		timeout: time.Second,
+		collectors: map[string]collector{"system": &resetCollector{
+			collector: uptime,
+			clock:     func() time.Time { return now },
+		}},
+	}
+
+	// no reset has been seen yet
+	err := testutil.CollectAndCompare(f, strings.NewReader(""), "freebox_system_uptime_seconds_reset_timestamp_seconds")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// the box rebooted, then kept running
+	expected := `
+# HELP freebox_system_uptime_seconds_reset_timestamp_seconds Last time freebox_system_uptime_seconds_total was seen going back to zero
+# TYPE freebox_system_uptime_seconds_reset_timestamp_seconds gauge
+freebox_system_uptime_seconds_reset_timestamp_seconds{firmware_version="4.2.0"} 1.6000006e+09
+`
+	now = now.Add(10 * time.Minute)
+	for _, uptime.uptime = range []float64{60, 660} {
+		err = testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_system_uptime_seconds_reset_timestamp_seconds")
+		if err != nil {
+			t.Error("Expected no err, but got", err)
+		}
+		now = now.Add(10 * time.Minute)
+	}
+
+	// the box went missing while rebooting
+	expected = `
+# HELP freebox_system_uptime_seconds_reset_timestamp_seconds Last time freebox_system_uptime_seconds_total was seen going back to zero
+# TYPE freebox_system_uptime_seconds_reset_timestamp_seconds gauge
+freebox_system_uptime_seconds_reset_timestamp_seconds{firmware_version="4.2.0"} 1.600003e+09
+`
+	for _, uptime.uptime = range []float64{-1, 30} {
+		now = now.Add(10 * time.Minute)
+		err = testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_system_uptime_seconds_reset_timestamp_seconds")
+		if uptime.uptime >= 0 && err != nil {
+			t.Error("Expected no err, but got", err)
+		}
+	}
+
+	// the state of a series gone for longer than counterTTL is dropped
+	reset := f.collectors["system"].(*resetCollector)
+	uptime.uptime = -1
+	for _, d := range []time.Duration{counterTTL, time.Second} {
+		now = now.Add(d)
+		testutil.CollectAndCompare(f, strings.NewReader(""), "freebox_system_uptime_seconds_reset_timestamp_seconds")
+	}
+	if len(reset.counters) != 0 {
+		t.Error("Expected 0, but got", len(reset.counters))
+	}
+}
+
+// gatherValues returns the values of the series of the metric name, by
+// their labels
+func gatherValues(t *testing.T, c prometheus.Collector, name string) map[string]float64 {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := []string{}
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			values[strings.Join(labels, ",")] = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return values
+}
+
+func TestXdslErrorsReset(t *testing.T) {
+	crc := 5
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, json.RawMessage(fmt.Sprintf(`{"status": {"status": "showtime"}, "down": {"crc": %d}}`, crc)))
+	}))
+	defer ts.Close()
+
+	reset := &resetCollector{collector: xdslCollector{}}
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"xdsl": reset},
+	}
+
+	errors := gatherValues(t, f, "freebox_connection_xdsl_errors_total")
+	if errors["direction=down,name=crc"] != 5 || errors["direction=up,name=crc"] != 0 {
+		t.Error("Expected 5 down and 0 up, zeros included, but got", errors)
+	}
+
+	resets := gatherValues(t, f, "freebox_connection_xdsl_errors_reset_timestamp_seconds")
+	if len(resets) != 0 {
+		t.Error("Expected no reset, but got", resets)
+	}
+
+	// the line resynchronized, then got errors again
+	before := time.Now().Unix()
+	for _, crc = range []int{0, 2} {
+		resets = gatherValues(t, f, "freebox_connection_xdsl_errors_reset_timestamp_seconds")
+		at := resets["direction=down,name=crc"]
+		if at < float64(before) || at > float64(time.Now().Unix()) {
+			t.Error("Expected the reset of the CRC errors, but got", resets)
+		}
+		if len(resets) != 1 {
+			t.Error("Expected 1 reset, but got", resets)
+		}
+	}
+
+}
+
+// hostsCollector reports the hosts it is given as reachable
+type hostsCollector struct {
+	hosts []string
+	iface string
+}
+
+func (c *hostsCollector) update(ctx context.Context, cl *client, ch chan<- prometheus.Metric) error {
+	for _, host := range c.hosts {
+		gauge(ch, lanReachableDesc, 1, host, "", "")
+	}
+	gauge(ch, lanInterfaceHostsDesc, float64(len(c.hosts)), c.iface)
+	return nil
+}
+
+func TestGraceCollector(t *testing.T) {
+	hosts := &hostsCollector{hosts: []string{"laptop", "phone"}, iface: "pub"}
+	grace := &graceCollector{collector: hosts, grace: time.Hour}
+	f := &freeboxCollector{
+		client:     newTestClient("http://127.0.0.1:0"),
+		timeout:    time.Second,
+		collectors: map[string]collector{"lan": grace},
+	}
+
+	both := `
+# HELP freebox_lan_reachable Hosts reachable on LAN
+# TYPE freebox_lan_reachable gauge
+freebox_lan_reachable{ip="",name="laptop",vendor=""} 1
+freebox_lan_reachable{ip="",name="phone",vendor=""} 1
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(both), "freebox_lan_reachable")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// the phone left within the grace period
+	hosts.hosts = []string{"laptop"}
+	err = testutil.CollectAndCompare(f, strings.NewReader(both), "freebox_lan_reachable")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// but the series of other families go at once
+	hosts.iface = "wifiguest"
+	expected := `
+# HELP freebox_lan_interface_hosts Number of hosts known on a LAN browser interface
+# TYPE freebox_lan_interface_hosts gauge
+freebox_lan_interface_hosts{interface="wifiguest"} 1
+`
+	err = testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_lan_interface_hosts")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// the grace period is over
+	grace.grace = 0
+	expected = `
+# HELP freebox_lan_reachable Hosts reachable on LAN
+# TYPE freebox_lan_reachable gauge
+freebox_lan_reachable{ip="",name="laptop",vendor=""} 1
+`
+	err = testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_lan_reachable")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if len(grace.seen) != 1 {
+		t.Error("Expected 1, but got", len(grace.seen))
+	}
+}
+
+func TestDroppedLabels(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, system{UptimeVal: 3600, FirmwareVersion: "4.2.0"})
+	}))
+	defer ts.Close()
+
+	cfg := defaultConfig()
+	cfg.Collectors = map[string]collectorConfig{}
+	for name := range collectorFactories {
+		enabled := name == "system"
+		cfg.Collectors[name] = collectorConfig{Enabled: &enabled}
+	}
+	cfg.Labels.Drop = []string{"firmware_version"}
+
+	f := newFreeboxCollector(newTestClient(ts.URL), cfg)
+
+	expected := `
+# HELP freebox_system_uptime_seconds_total Freebox Server uptime (in seconds)
+# TYPE freebox_system_uptime_seconds_total counter
+freebox_system_uptime_seconds_total 3600
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_system_uptime_seconds_total")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestMediaDetection(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/connection/":
+			writeResult(w, connectionStatus{Type: "ethernet", Media: "ftth"})
+		case "/api/v4/system/":
+			writeResult(w, system{})
+		default:
+			writeError(w, "internal_error")
+		}
+	}))
+	defer ts.Close()
+
+	cfg := defaultConfig()
+	cfg.Collectors = map[string]collectorConfig{}
+	for name := range collectorFactories {
+		if name != "system" && name != "xdsl" {
+			cfg.setEnabled(name, false)
+		}
+	}
+
+	// the DSL metrics are left out on a fiber connection
+	expected := `
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="system"} 1
+`
+	f := newFreeboxCollector(newTestClient(ts.URL), cfg)
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_scrape_collector_success")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// unless the xdsl collector is forced
+	expected = `
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="system"} 1
+freebox_scrape_collector_success{collector="xdsl"} 0
+`
+	cfg.setEnabled("xdsl", true)
+	f = newFreeboxCollector(newTestClient(ts.URL), cfg)
+	err = testutil.CollectAndCompare(f, strings.NewReader(expected), "freebox_scrape_collector_success")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestFtthCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"success":true,"result":{"sfp_has_power_report":true,"sfp_has_signal":true,
+			"sfp_model":"F-MDCONU3A","sfp_vendor":"FREEBOX","sfp_pwr_tx":415,"sfp_alim_ok":true,
+			"sfp_serial":"FBX0123456789","link":true,"sfp_present":true,"sfp_pwr_rx":-1906}}`)
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"ftth": ftthCollector{}},
+	}
+
+	expected := `
+# HELP freebox_connection_ftth_link Whether the FTTH link is up
+# TYPE freebox_connection_ftth_link gauge
+freebox_connection_ftth_link 1
+# HELP freebox_connection_ftth_sfp_info SFP model, vendor and serial number
+# TYPE freebox_connection_ftth_sfp_info gauge
+freebox_connection_ftth_sfp_info{model="F-MDCONU3A",serial="FBX0123456789",vendor="FREEBOX"} 1
+# HELP freebox_connection_ftth_sfp_rx_power_dbm Optical power received by the SFP (in dBm)
+# TYPE freebox_connection_ftth_sfp_rx_power_dbm gauge
+freebox_connection_ftth_sfp_rx_power_dbm -19.06
+# HELP freebox_connection_ftth_sfp_tx_power_dbm Optical power transmitted by the SFP (in dBm)
+# TYPE freebox_connection_ftth_sfp_tx_power_dbm gauge
+freebox_connection_ftth_sfp_tx_power_dbm 4.15
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_connection_ftth_link", "freebox_connection_ftth_sfp_info",
+		"freebox_connection_ftth_sfp_rx_power_dbm", "freebox_connection_ftth_sfp_tx_power_dbm")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestConnectionCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"success":true,"result":{"type":"ethernet","rate_down":4028,"bytes_up":1202483423,
+			"ipv4_port_range":[0,65535],"rate_up":2542,"bandwidth_up":700000000,"ipv6":"2a01:e35:2f5a:a950::1",
+			"bandwidth_down":1000000000,"media":"ftth","state":"up","bytes_down":2918398417,"ipv4":"82.245.170.149"}}`)
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"connection": connectionCollector{}},
+	}
+
+	expected := `
+# HELP freebox_connection_bytes_total Bytes transferred over the WAN connection
+# TYPE freebox_connection_bytes_total counter
+freebox_connection_bytes_total{direction="down"} 2.918398417e+09
+freebox_connection_bytes_total{direction="up"} 1.202483423e+09
+# HELP freebox_connection_info Type, media and IP addresses of the WAN connection
+# TYPE freebox_connection_info gauge
+freebox_connection_info{ipv4="82.245.170.149",ipv6="2a01:e35:2f5a:a950::1",media="ftth",type="ethernet"} 1
+# HELP freebox_connection_state State of the WAN connection
+# TYPE freebox_connection_state gauge
+freebox_connection_state{state="down"} 0
+freebox_connection_state{state="going_down"} 0
+freebox_connection_state{state="going_up"} 0
+freebox_connection_state{state="up"} 1
+# HELP freebox_connection_rate_bytes_per_second Current rate of the WAN connection (in byte/s)
+# TYPE freebox_connection_rate_bytes_per_second gauge
+freebox_connection_rate_bytes_per_second{direction="down"} 4028
+freebox_connection_rate_bytes_per_second{direction="up"} 2542
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_connection_bytes_total", "freebox_connection_info", "freebox_connection_state",
+		"freebox_connection_rate_bytes_per_second")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestConnectionConfigCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/connection/config/":
+			writeResult(w, connectionConfig{Ping: true, Wol: false, Adblock: true, RemoteAccessPort: 8443})
+		case "/api/v4/connection/ipv6/config/":
+			fmt.Fprintln(w, `{"success":true,"result":{"ipv6_enabled":true,"ipv6_firewall":true,
+				"ipv6ll":"fe80::224:d4ff:feb4:71c0","delegations":[{"prefix":"2a01:e35:2f5a:a951::/64","next_hop":""}]}}`)
+		case "/api/v4/connection/ddns/ovh/status/":
+			writeResult(w, ddnsStatus{Status: "reqfail", LastRefresh: 1589000000, LastError: 1589003600, NextRepeat: 1589007200})
+		case "/api/v4/connection/ddns/dyndns/status/":
+			writeResult(w, ddnsStatus{Status: "disabled"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"connection_config": connectionConfigCollector{}},
+	}
+
+	expected := `
+# HELP freebox_connection_config_info Configuration of the WAN connection
+# TYPE freebox_connection_config_info gauge
+freebox_connection_config_info{adblock="true",allow_token_request="false",api_remote_access="false",is_secure_pass="false",ping="true",remote_access="false",remote_access_port="8443",wol="false"} 1
+# HELP freebox_connection_ddns_last_error_timestamp_seconds Last failed refresh of a DynDNS provider
+# TYPE freebox_connection_ddns_last_error_timestamp_seconds gauge
+freebox_connection_ddns_last_error_timestamp_seconds{provider="ovh"} 1.5890036e+09
+# HELP freebox_connection_ddns_status_info Status of a DynDNS provider
+# TYPE freebox_connection_ddns_status_info gauge
+freebox_connection_ddns_status_info{provider="dyndns",status="disabled"} 1
+freebox_connection_ddns_status_info{provider="ovh",status="reqfail"} 1
+# HELP freebox_connection_ipv6_delegation_info IPv6 prefix delegated to a next hop
+# TYPE freebox_connection_ipv6_delegation_info gauge
+freebox_connection_ipv6_delegation_info{next_hop="",prefix="2a01:e35:2f5a:a951::/64"} 1
+# HELP freebox_connection_ipv6_info IPv6 configuration of the WAN connection
+# TYPE freebox_connection_ipv6_info gauge
+freebox_connection_ipv6_info{enabled="true",firewall="true",link_local="fe80::224:d4ff:feb4:71c0"} 1
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="connection_config"} 1
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_connection_config_info", "freebox_connection_ddns_last_error_timestamp_seconds",
+		"freebox_connection_ddns_status_info", "freebox_connection_ipv6_delegation_info",
+		"freebox_connection_ipv6_info", "freebox_scrape_collector_success")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestSwitchCollector(t *testing.T) {
+	browsed := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/switch/status/":
+			writeResult(w, []switchPortStatus{
+				{ID: 1, Link: "up", Mode: "1000BaseT-FD", Speed: "1000", Duplex: "full"},
+				{ID: 2, Link: "down", Mode: "", Speed: "", Duplex: ""},
+			})
+		case "/api/v4/switch/port/1/stats/":
+			writeResult(w, switchPortStats{RxGoodBytes: 1000, TxBytes: 2000, TxCollisions: 3})
+		case "/api/v4/switch/port/2/stats/":
+			writeResult(w, switchPortStats{})
+		case "/api/v4/switch/port/1/":
+			fmt.Fprintln(w, `{"success":true,"result":{"id":1,"mac_list":[
+				{"mac":"00:24:D4:B4:71:C0","hostname":"freebox-player"},
+				{"mac":"DC:A6:32:00:00:01","hostname":"raspberrypi"}]}}`)
+		case "/api/v4/switch/port/2/":
+			writeResult(w, switchPortConfig{ID: 2})
+		case "/api/v4/lan/browser/pub/":
+			browsed++
+			writeResult(w, []lanHost{{PrimaryName: "Freebox Player", L2Ident: l2Ident{ID: "00:24:d4:b4:71:c0", Type: "mac_address"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"switch": switchCollector{}, "lan": lanCollector{}},
+	}
+
+	expected := `
+# HELP freebox_switch_port_bytes_total Bytes transferred by a switch port
+# TYPE freebox_switch_port_bytes_total counter
+freebox_switch_port_bytes_total{direction="rx",port="1"} 1000
+freebox_switch_port_bytes_total{direction="rx",port="2"} 0
+freebox_switch_port_bytes_total{direction="tx",port="1"} 2000
+freebox_switch_port_bytes_total{direction="tx",port="2"} 0
+# HELP freebox_switch_port_collisions_total Collisions on a switch port
+# TYPE freebox_switch_port_collisions_total counter
+freebox_switch_port_collisions_total{port="1"} 3
+freebox_switch_port_collisions_total{port="2"} 0
+# HELP freebox_switch_port_host_info Host seen behind a switch port, named after the LAN browser
+# TYPE freebox_switch_port_host_info gauge
+freebox_switch_port_host_info{hostname="Freebox Player",mac="00:24:D4:B4:71:C0",port="1"} 1
+freebox_switch_port_host_info{hostname="raspberrypi",mac="DC:A6:32:00:00:01",port="1"} 1
+# HELP freebox_switch_port_link Whether the link of a switch port is up
+# TYPE freebox_switch_port_link gauge
+freebox_switch_port_link{port="1"} 1
+freebox_switch_port_link{port="2"} 0
+# HELP freebox_switch_port_speed_bits_per_second Negotiated speed of a switch port (in bit/s)
+# TYPE freebox_switch_port_speed_bits_per_second gauge
+freebox_switch_port_speed_bits_per_second{port="1"} 1e+09
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_switch_port_bytes_total", "freebox_switch_port_collisions_total",
+		"freebox_switch_port_host_info", "freebox_switch_port_link", "freebox_switch_port_speed_bits_per_second",
+		"freebox_switch_port_rate_bytes_per_second")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// the lan and switch collectors share the LAN browser of the scrape
+	if browsed != 1 {
+		t.Error("Expected 1, but got", browsed)
+	}
+}
+
+func TestDhcpCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/dhcp/config/":
+			writeResult(w, dhcpConfig{
+				Enabled:      true,
+				StickyAssign: true,
+				Gateway:      "192.168.1.254",
+				Netmask:      "255.255.255.0",
+				IPRangeStart: "192.168.1.10",
+				IPRangeEnd:   "192.168.1.13",
+				DNS:          []string{"192.168.1.254"},
+			})
+		case "/api/v4/dhcp/static_lease/":
+			writeResult(w, []dhcpStaticLease{
+				{ID: "00:24:D4:B4:71:C0", Mac: "00:24:D4:B4:71:C0", Hostname: "Freebox Player", IP: "192.168.1.2", Host: lanHost{Reachable: true}},
+			})
+		case "/api/v4/dhcp/dynamic_lease/":
+			writeResult(w, []dhcpDynamicLease{
+				{Mac: "00:24:D4:B4:71:C0", Hostname: "Freebox Player", IP: "192.168.1.2", LeaseRemaining: 86000, IsStatic: true},
+				{Mac: "3C:22:FB:00:00:01", Hostname: "Laptop", IP: "192.168.1.10", LeaseRemaining: 3600},
+			})
+		case "/api/v4/dhcpv6/config/":
+			writeResult(w, dhcpv6Config{Enabled: false})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"dhcp": dhcpCollector{}},
+	}
+
+	// the static lease out of the dynamic range does not use the pool
+	expected := `
+# HELP freebox_dhcp_enabled Whether the DHCP server is enabled
+# TYPE freebox_dhcp_enabled gauge
+freebox_dhcp_enabled{family="ipv4"} 1
+freebox_dhcp_enabled{family="ipv6"} 0
+# HELP freebox_dhcp_lease_expiry_seconds Seconds until a dynamic lease expires
+# TYPE freebox_dhcp_lease_expiry_seconds gauge
+freebox_dhcp_lease_expiry_seconds{family="ipv4",hostname="Freebox Player",ip="192.168.1.2",mac="00:24:D4:B4:71:C0"} 86000
+freebox_dhcp_lease_expiry_seconds{family="ipv4",hostname="Laptop",ip="192.168.1.10",mac="3C:22:FB:00:00:01"} 3600
+# HELP freebox_dhcp_leases Number of leases of the DHCP server
+# TYPE freebox_dhcp_leases gauge
+freebox_dhcp_leases{family="ipv4",type="dynamic"} 2
+freebox_dhcp_leases{family="ipv4",type="static"} 1
+freebox_dhcp_leases{family="ipv6",type="dynamic"} 0
+freebox_dhcp_leases{family="ipv6",type="static"} 0
+# HELP freebox_dhcp_pool_size Number of addresses in the dynamic range of the DHCP server
+# TYPE freebox_dhcp_pool_size gauge
+freebox_dhcp_pool_size 4
+# HELP freebox_dhcp_pool_utilization_ratio Share of the dynamic range of the DHCP server which is leased
+# TYPE freebox_dhcp_pool_utilization_ratio gauge
+freebox_dhcp_pool_utilization_ratio 0.25
+# HELP freebox_dhcp_static_lease_online Whether the host of a static lease is reachable
+# TYPE freebox_dhcp_static_lease_online gauge
+freebox_dhcp_static_lease_online{family="ipv4",hostname="Freebox Player",ip="192.168.1.2",mac="00:24:D4:B4:71:C0"} 1
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="dhcp"} 1
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_dhcp_enabled", "freebox_dhcp_lease_expiry_seconds", "freebox_dhcp_leases", "freebox_dhcp_pool_size",
+		"freebox_dhcp_pool_utilization_ratio", "freebox_dhcp_static_lease_online", "freebox_scrape_collector_success")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestFirewallCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/fw/redir/":
+			writeResult(w, []fwRedir{
+				{ID: 1, Enabled: true, IPProto: "tcp", WanPortStart: 8080, WanPortEnd: 8081, LanIP: "192.168.1.10", LanPort: 80, Comment: "web"},
+				{ID: 2, Enabled: false, IPProto: "udp", WanPortStart: 51820, WanPortEnd: 51820, LanIP: "192.168.1.11", LanPort: 51820},
+			})
+		case "/api/v4/fw/incoming/":
+			writeResult(w, []fwIncoming{{ID: "bittorrent-main", Enabled: true, Type: "tcp_udp", InPort: 45000}})
+		case "/api/v4/fw/dmz/":
+			writeResult(w, fwDmz{Enabled: false, IP: ""})
+		case "/api/v4/upnpigd/redir/":
+			writeResult(w, []upnpRedir{
+				{ID: "0.0.0.0-3074-udp", Enabled: true, ExtPort: 3074, IntIP: "192.168.1.12", IntPort: 3074, Proto: "udp", Desc: "Xbox", Remaining: 3500},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"firewall": firewallCollector{}},
+	}
+
+	expected := `
+# HELP freebox_fw_dmz_enabled Whether the DMZ is enabled
+# TYPE freebox_fw_dmz_enabled gauge
+freebox_fw_dmz_enabled{ip=""} 0
+# HELP freebox_fw_incoming_info Enabled incoming port of a Freebox service
+# TYPE freebox_fw_incoming_info gauge
+freebox_fw_incoming_info{id="bittorrent-main",port="45000",proto="tcp_udp"} 1
+# HELP freebox_fw_redir_info Enabled port forwarding
+# TYPE freebox_fw_redir_info gauge
+freebox_fw_redir_info{comment="web",id="1",lan_ip="192.168.1.10",lan_port="80",proto="tcp",src_ip="",wan_port_end="8081",wan_port_start="8080"} 1
+# HELP freebox_fw_wan_ports Number of WAN ports forwarded to the LAN or opened for a Freebox service
+# TYPE freebox_fw_wan_ports gauge
+freebox_fw_wan_ports{source="incoming"} 1
+freebox_fw_wan_ports{source="redir"} 2
+freebox_fw_wan_ports{source="upnp"} 1
+# HELP freebox_upnpigd_redir_info Enabled UPnP IGD redirection
+# TYPE freebox_upnpigd_redir_info gauge
+freebox_upnpigd_redir_info{description="Xbox",ext_port="3074",ext_src_ip="",int_ip="192.168.1.12",int_port="3074",proto="udp"} 1
+# HELP freebox_upnpigd_redir_remaining_seconds Seconds until a UPnP IGD redirection expires
+# TYPE freebox_upnpigd_redir_remaining_seconds gauge
+freebox_upnpigd_redir_remaining_seconds{ext_port="3074",proto="udp"} 3500
+# HELP freebox_upnpigd_redirs Number of enabled UPnP IGD redirections
+# TYPE freebox_upnpigd_redirs gauge
+freebox_upnpigd_redirs 1
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_fw_dmz_enabled", "freebox_fw_incoming_info", "freebox_fw_redir_info", "freebox_fw_wan_ports",
+		"freebox_upnpigd_redir_info", "freebox_upnpigd_redir_remaining_seconds", "freebox_upnpigd_redirs")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestWifiCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/wifi/ap/":
+			writeResult(w, json.RawMessage(`[
+				{"id": 0, "name": "5G", "status": {"state": "active", "channel_width": "80", "primary_channel": 36, "secondary_channel": 42},
+				 "config": {"band": "5g", "channel_width": "80", "dfs_enabled": true}},
+				{"id": 2, "name": "6G", "status": {"state": "dfs", "channel_width": "160", "primary_channel": 37, "dfs_cac_remaining_time": 42},
+				 "config": {"band": "6g", "channel_width": "160"}}
+			]`))
+		case "/api/v4/wifi/bss/":
+			writeResult(w, json.RawMessage(`[
+				{"id": "F4:CA:E5:00:00:01", "phy_id": 0, "status": {"state": "active", "sta_count": 3, "authorized_sta_count": 2},
+				 "use_shared_params": true, "shared_bss_params": {"enabled": true, "ssid": "Freebox", "encryption": "wpa2_psk_ccmp"}},
+				{"id": "F4:CA:E5:00:00:02", "phy_id": 2, "status": {"state": "disabled"},
+				 "use_shared_params": false, "bss_params": {"enabled": false, "ssid": "Freebox-6E", "encryption": "wpa3_sae"}}
+			]`))
+		case "/api/v4/wifi/ap/0/stations", "/api/v4/wifi/ap/2/stations":
+			writeResult(w, []wifiStation{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"wifi": wifiCollector{}},
+	}
+
+	expected := `
+# HELP freebox_wifi_ap_channel Channel in use by a Wi-Fi access point
+# TYPE freebox_wifi_ap_channel gauge
+freebox_wifi_ap_channel{access_point="5G",type="primary"} 36
+freebox_wifi_ap_channel{access_point="5G",type="secondary"} 42
+freebox_wifi_ap_channel{access_point="6G",type="primary"} 37
+# HELP freebox_wifi_ap_dfs_cac_remaining_seconds Seconds left before a Wi-Fi access point may emit on a DFS channel
+# TYPE freebox_wifi_ap_dfs_cac_remaining_seconds gauge
+freebox_wifi_ap_dfs_cac_remaining_seconds{access_point="5G"} 0
+freebox_wifi_ap_dfs_cac_remaining_seconds{access_point="6G"} 42
+# HELP freebox_wifi_ap_info Band and channel width of a Wi-Fi access point
+# TYPE freebox_wifi_ap_info gauge
+freebox_wifi_ap_info{access_point="5G",band="5g",channel_width="80"} 1
+freebox_wifi_ap_info{access_point="6G",band="6g",channel_width="160"} 1
+# HELP freebox_wifi_bss_info SSID and encryption of a Wi-Fi BSS
+# TYPE freebox_wifi_bss_info gauge
+freebox_wifi_bss_info{access_point="5G",bssid="F4:CA:E5:00:00:01",encryption="wpa2_psk_ccmp",hidden="false",ssid="Freebox"} 1
+freebox_wifi_bss_info{access_point="6G",bssid="F4:CA:E5:00:00:02",encryption="wpa3_sae",hidden="false",ssid="Freebox-6E"} 1
+# HELP freebox_wifi_bss_stations Number of stations associated to a Wi-Fi BSS
+# TYPE freebox_wifi_bss_stations gauge
+freebox_wifi_bss_stations{authorized="false",bssid="F4:CA:E5:00:00:01"} 1
+freebox_wifi_bss_stations{authorized="false",bssid="F4:CA:E5:00:00:02"} 0
+freebox_wifi_bss_stations{authorized="true",bssid="F4:CA:E5:00:00:01"} 2
+freebox_wifi_bss_stations{authorized="true",bssid="F4:CA:E5:00:00:02"} 0
+# HELP freebox_wifi_bss_enabled Whether a Wi-Fi BSS is enabled
+# TYPE freebox_wifi_bss_enabled gauge
+freebox_wifi_bss_enabled{bssid="F4:CA:E5:00:00:01"} 1
+freebox_wifi_bss_enabled{bssid="F4:CA:E5:00:00:02"} 0
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_wifi_ap_channel", "freebox_wifi_ap_dfs_cac_remaining_seconds", "freebox_wifi_ap_info",
+		"freebox_wifi_bss_info", "freebox_wifi_bss_stations", "freebox_wifi_bss_enabled")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+}
+
+func TestWifiStations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/wifi/ap/":
+			writeResult(w, json.RawMessage(`[{"id": 0, "name": "5G", "config": {"band": "5g"}}]`))
+		case "/api/v4/wifi/bss/":
+			writeResult(w, []wifiBss{})
+		case "/api/v4/wifi/ap/0/stations":
+			// two stations with the same hostname stay apart
+			writeResult(w, []wifiStation{
+				{
+					Hostname: "iPhone", MAC: "AA:BB:CC:DD:EE:01", State: "authenticated",
+					RXBytes: 500, TXBytes: 2280000000, TXRetries: 12, TXFailed: 1,
+					Flags:  wifiStationFlags{HT: true, VHT: true, HE: true, Authorized: true},
+					LastRX: wifiStationRate{Bitrate: 12010, VHTMCS: 11, Width: "80", NSS: 2},
+					LastTX: wifiStationRate{Bitrate: 8640, VHTMCS: 9, Width: "80", NSS: 2},
+				},
+				{
+					Hostname: "iPhone", MAC: "AA:BB:CC:DD:EE:02", State: "associated",
+					Flags:  wifiStationFlags{HT: true, Powersave: true},
+					LastRX: wifiStationRate{Bitrate: 650, MCS: 7, Width: "20", NSS: 1},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"wifi": wifiCollector{}},
+	}
+
+	expected := `
+# HELP freebox_wifi_rx_bytes_total Wifi received data (from station to Freebox) in bytes
+# TYPE freebox_wifi_rx_bytes_total counter
+freebox_wifi_rx_bytes_total{access_point="5G",mac="AA:BB:CC:DD:EE:01"} 500
+freebox_wifi_rx_bytes_total{access_point="5G",mac="AA:BB:CC:DD:EE:02"} 0
+# HELP freebox_wifi_station_info Hostname, band and standard of a Wi-Fi station
+# TYPE freebox_wifi_station_info gauge
+freebox_wifi_station_info{access_point="5G",band="5g",hostname="iPhone",mac="AA:BB:CC:DD:EE:01",standard="ax"} 1
+freebox_wifi_station_info{access_point="5G",band="5g",hostname="iPhone",mac="AA:BB:CC:DD:EE:02",standard="n"} 1
+# HELP freebox_wifi_station_mcs MCS index of the last frame exchanged with a Wi-Fi station
+# TYPE freebox_wifi_station_mcs gauge
+freebox_wifi_station_mcs{access_point="5G",direction="rx",mac="AA:BB:CC:DD:EE:01"} 11
+freebox_wifi_station_mcs{access_point="5G",direction="rx",mac="AA:BB:CC:DD:EE:02"} 7
+freebox_wifi_station_mcs{access_point="5G",direction="tx",mac="AA:BB:CC:DD:EE:01"} 9
+freebox_wifi_station_mcs{access_point="5G",direction="tx",mac="AA:BB:CC:DD:EE:02"} 0
+# HELP freebox_wifi_station_phy_rate_bits_per_second PHY rate of the last frame exchanged with a Wi-Fi station (in bit/s)
+# TYPE freebox_wifi_station_phy_rate_bits_per_second gauge
+freebox_wifi_station_phy_rate_bits_per_second{access_point="5G",direction="rx",mac="AA:BB:CC:DD:EE:01"} 1.201e+09
+freebox_wifi_station_phy_rate_bits_per_second{access_point="5G",direction="rx",mac="AA:BB:CC:DD:EE:02"} 6.5e+07
+freebox_wifi_station_phy_rate_bits_per_second{access_point="5G",direction="tx",mac="AA:BB:CC:DD:EE:01"} 8.64e+08
+freebox_wifi_station_phy_rate_bits_per_second{access_point="5G",direction="tx",mac="AA:BB:CC:DD:EE:02"} 0
+# HELP freebox_wifi_station_tx_retries_total Frames retransmitted to a Wi-Fi station
+# TYPE freebox_wifi_station_tx_retries_total counter
+freebox_wifi_station_tx_retries_total{access_point="5G",mac="AA:BB:CC:DD:EE:01"} 12
+freebox_wifi_station_tx_retries_total{access_point="5G",mac="AA:BB:CC:DD:EE:02"} 0
+# HELP freebox_wifi_station_flag Capabilities and state of a Wi-Fi station
+# TYPE freebox_wifi_station_flag gauge
+freebox_wifi_station_flag{access_point="5G",flag="authorized",mac="AA:BB:CC:DD:EE:01"} 1
+freebox_wifi_station_flag{access_point="5G",flag="authorized",mac="AA:BB:CC:DD:EE:02"} 0
+freebox_wifi_station_flag{access_point="5G",flag="he",mac="AA:BB:CC:DD:EE:01"} 1
+freebox_wifi_station_flag{access_point="5G",flag="he",mac="AA:BB:CC:DD:EE:02"} 0
+freebox_wifi_station_flag{access_point="5G",flag="powersave",mac="AA:BB:CC:DD:EE:01"} 0
+freebox_wifi_station_flag{access_point="5G",flag="powersave",mac="AA:BB:CC:DD:EE:02"} 1
+freebox_wifi_station_flag{access_point="5G",flag="vht",mac="AA:BB:CC:DD:EE:01"} 1
+freebox_wifi_station_flag{access_point="5G",flag="vht",mac="AA:BB:CC:DD:EE:02"} 0
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_wifi_rx_bytes_total", "freebox_wifi_station_info", "freebox_wifi_station_mcs",
+		"freebox_wifi_station_phy_rate_bits_per_second", "freebox_wifi_station_tx_retries_total", "freebox_wifi_station_flag")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestWifiSurveyCollector(t *testing.T) {
+	scans := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/wifi/ap/":
+			writeResult(w, []wifiAccessPoint{{ID: 0, Name: "2.4G"}})
+		case "/api/v4/wifi/ap/0/channel_usage/":
+			scans++
+			writeResult(w, []wifiChannelUsage{{Band: "2d4g", Channel: 6, NoiseLevel: -92, RxBusyPercent: 30, TxPercent: 5, BusyPercent: 45}})
+		case "/api/v4/wifi/ap/0/neighbors/":
+			writeResult(w, []wifiNeighbor{{BSSID: "00:07:CB:00:00:01", SSID: "Voisin", Band: "2d4g", ChannelWidth: "20", Channel: 6, Signal: -71}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cfg := defaultConfig()
+	cfg.Collectors = map[string]collectorConfig{}
+	for name := range collectorFactories {
+		enabled := name == "wifi_survey"
+		cfg.Collectors[name] = collectorConfig{Enabled: &enabled}
+	}
+
+	f := newFreeboxCollector(newTestClient(ts.URL), cfg)
+	if s, ok := f.collectors["wifi_survey"].(*scheduledCollector); !ok || s.interval != 10*time.Minute {
+		t.Error("Expected the survey to run every 10 minutes, but got", f.collectors["wifi_survey"])
+	}
+
+	expected := `
+# HELP freebox_wifi_channel_busy_percent Share of the time a Wi-Fi channel is busy, as seen by an access point
+# TYPE freebox_wifi_channel_busy_percent gauge
+freebox_wifi_channel_busy_percent{access_point="2.4G",band="2d4g",channel="6",type="rx"} 30
+freebox_wifi_channel_busy_percent{access_point="2.4G",band="2d4g",channel="6",type="total"} 45
+freebox_wifi_channel_busy_percent{access_point="2.4G",band="2d4g",channel="6",type="tx"} 5
+# HELP freebox_wifi_channel_noise_dbm Noise level on a Wi-Fi channel, as seen by an access point
+# TYPE freebox_wifi_channel_noise_dbm gauge
+freebox_wifi_channel_noise_dbm{access_point="2.4G",band="2d4g",channel="6"} -92
+# HELP freebox_wifi_neighbor_signal_dbm Signal of a neighbouring Wi-Fi BSS heard by an access point
+# TYPE freebox_wifi_neighbor_signal_dbm gauge
+freebox_wifi_neighbor_signal_dbm{access_point="2.4G",band="2d4g",bssid="00:07:CB:00:00:01",channel="6",channel_width="20",ssid="Voisin"} -71
+`
+	for i := 0; i < 2; i++ {
+		err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+			"freebox_wifi_channel_busy_percent", "freebox_wifi_channel_noise_dbm", "freebox_wifi_neighbor_signal_dbm")
+		if err != nil {
+			t.Error("Expected no err, but got", err)
+		}
+	}
+
+	if scans != 1 {
+		t.Error("Expected 1, but got", scans)
+	}
+}
+
+func TestLanCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/lan/browser/interfaces/":
+			writeResult(w, []lanInterface{{Name: "pub", HostCount: 3}, {Name: "wifiguest", HostCount: 1}})
+		case "/api/v4/lan/browser/pub/":
+			writeResult(w, []lanHost{
+				{
+					ID:                "ether-00:24:d4:b4:71:c0",
+					Reachable:         true,
+					Active:            true,
+					PrimaryName:       "Freebox Player",
+					HostType:          "freebox_player",
+					Vendor_name:       "Freebox SAS",
+					LastActivity:      1600000100,
+					FirstActivity:     1500000000,
+					LastTimeReachable: 1600000090,
+					L2Ident:           l2Ident{ID: "00:24:D4:B4:71:C0", Type: "mac_address"},
+					L3c: []l3c{
+						{Addr: "192.168.1.10", Af: "ipv4", Active: true, Reachable: true},
+						{Addr: "fe80::224:d4ff:feb4:71c0", Af: "ipv6"},
+					},
+				},
+				{
+					PrimaryName: "Laptop",
+					HostType:    "workstation",
+					L2Ident:     l2Ident{ID: "3C:22:FB:00:00:01", Type: "mac_address"},
+				},
+				{
+					Reachable:   true,
+					PrimaryName: "Laptop",
+					HostType:    "workstation",
+					L2Ident:     l2Ident{ID: "3C:22:FB:00:00:02", Type: "mac_address"},
+				},
+			})
+		case "/api/v4/lan/browser/wifiguest/":
+			writeResult(w, []lanHost{
+				{
+					Reachable:   true,
+					PrimaryName: "Phone",
+					HostType:    "smartphone",
+					L2Ident:     l2Ident{ID: "5A:11:00:00:00:01", Type: "mac_address"},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:     newTestClient(ts.URL),
+		timeout:    time.Second,
+		collectors: map[string]collector{"lan": lanCollector{}},
+	}
+
+	expected := `
+# HELP freebox_lan_host_active Whether the host is active on the LAN
+# TYPE freebox_lan_host_active gauge
+freebox_lan_host_active{interface="pub",mac="00:24:D4:B4:71:C0"} 1
+freebox_lan_host_active{interface="pub",mac="3C:22:FB:00:00:01"} 0
+freebox_lan_host_active{interface="pub",mac="3C:22:FB:00:00:02"} 0
+freebox_lan_host_active{interface="wifiguest",mac="5A:11:00:00:00:01"} 0
+# HELP freebox_lan_host_address_active Whether an address of the host is active
+# TYPE freebox_lan_host_address_active gauge
+freebox_lan_host_address_active{address="192.168.1.10",family="ipv4",interface="pub",mac="00:24:D4:B4:71:C0"} 1
+freebox_lan_host_address_active{address="fe80::224:d4ff:feb4:71c0",family="ipv6",interface="pub",mac="00:24:D4:B4:71:C0"} 0
+# HELP freebox_lan_host_info Host known to the LAN browser
+# TYPE freebox_lan_host_info gauge
+freebox_lan_host_info{host_type="freebox_player",interface="pub",mac="00:24:D4:B4:71:C0",name="Freebox Player",vendor="Freebox SAS"} 1
+freebox_lan_host_info{host_type="workstation",interface="pub",mac="3C:22:FB:00:00:01",name="Laptop",vendor=""} 1
+freebox_lan_host_info{host_type="workstation",interface="pub",mac="3C:22:FB:00:00:02",name="Laptop",vendor=""} 1
+freebox_lan_host_info{host_type="smartphone",interface="wifiguest",mac="5A:11:00:00:00:01",name="Phone",vendor=""} 1
+# HELP freebox_lan_host_last_activity_timestamp_seconds Last time the host sent traffic
+# TYPE freebox_lan_host_last_activity_timestamp_seconds gauge
+freebox_lan_host_last_activity_timestamp_seconds{interface="pub",mac="00:24:D4:B4:71:C0"} 1.6000001e+09
+# HELP freebox_lan_host_type_hosts Number of hosts known on the LAN by type
+# TYPE freebox_lan_host_type_hosts gauge
+freebox_lan_host_type_hosts{host_type="freebox_player"} 1
+freebox_lan_host_type_hosts{host_type="smartphone"} 1
+freebox_lan_host_type_hosts{host_type="workstation"} 2
+# HELP freebox_lan_interface_hosts Number of hosts known on a LAN browser interface
+# TYPE freebox_lan_interface_hosts gauge
+freebox_lan_interface_hosts{interface="pub"} 3
+freebox_lan_interface_hosts{interface="wifiguest"} 1
+# HELP freebox_lan_reachable Hosts reachable on LAN
+# TYPE freebox_lan_reachable gauge
+freebox_lan_reachable{ip="",name="Laptop",vendor=""} 1
+freebox_lan_reachable{ip="192.168.1.10",name="Freebox Player",vendor="Freebox SAS"} 1
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_lan_host_active", "freebox_lan_host_address_active", "freebox_lan_host_info",
+		"freebox_lan_host_last_activity_timestamp_seconds", "freebox_lan_host_type_hosts", "freebox_lan_interface_hosts",
+		"freebox_lan_reachable")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestRRDCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := database{}
+		json.NewDecoder(r.Body).Decode(&d)
+
+		switch d.DB {
+		case "net":
+			writeResult(w, rrd{Data: []map[string]int64{
+				{"time": 1600000000, "rate_down": 1000, "tx_bytes": 3},
+				{"time": 1600000010, "rate_down": 2000, "tx_bytes": 4},
+			}})
+		case "switch":
+			writeResult(w, rrd{Data: []map[string]int64{{"time": 1600000005, "rx_1": 10, "tx_1": 20}}})
+		default:
+			writeError(w, "invalid_request")
+		}
+	}))
+	defer ts.Close()
+
+	f := &freeboxCollector{
+		client:  newTestClient(ts.URL),
+		timeout: time.Second,
+		collectors: map[string]collector{"rrd": newRRDCollector(&config{RRD: []rrdMetric{
+			{DB: "net", Field: "rate_down", Name: "freebox_net_down", Unit: "bits", Scale: 8, Help: "Download rate"},
+			{DB: "net", Field: "tx_bytes", Name: "freebox_net_tx_total", Unit: "bytes", Type: "counter", Help: "Sent bytes"},
+			{DB: "switch", Field: "rx_1", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate", Labels: map[string]string{"port": "1", "direction": "rx"}},
+			{DB: "switch", Field: "tx_1", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate", Labels: map[string]string{"port": "1", "direction": "tx"}},
+			{DB: "fbxconnman", Field: "rate_down", Name: "freebox_connman_down", Help: "Unknown database"},
+		}})},
+	}
+
+	// the newest point is sent with its own time
+	expected := `
+# HELP freebox_net_down_bits Download rate
+# TYPE freebox_net_down_bits gauge
+freebox_net_down_bits 16000 1600000010000
+# HELP freebox_net_tx_bytes_total Sent bytes
+# TYPE freebox_net_tx_bytes_total counter
+freebox_net_tx_bytes_total 4 1600000010000
+# HELP freebox_switch_port_rate_bytes_per_second Rate
+# TYPE freebox_switch_port_rate_bytes_per_second gauge
+freebox_switch_port_rate_bytes_per_second{direction="rx",port="1"} 10 1600000005000
+freebox_switch_port_rate_bytes_per_second{direction="tx",port="1"} 20 1600000005000
+# HELP freebox_scrape_collector_success Whether a collector succeeded
+# TYPE freebox_scrape_collector_success gauge
+freebox_scrape_collector_success{collector="rrd"} 1
+`
+	err := testutil.CollectAndCompare(f, strings.NewReader(expected),
+		"freebox_net_down_bits", "freebox_net_tx_bytes_total", "freebox_switch_port_rate_bytes_per_second",
+		"freebox_connman_down", "freebox_scrape_collector_success")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
+
+func TestRRDLineDatabases(t *testing.T) {
+	media := "ftth"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/api/v4/connection/":
+			writeResult(w, connectionStatus{Type: "ethernet", Media: media})
+		case "/api/v4/rrd/":
+			d := database{}
+			json.NewDecoder(r.Body).Decode(&d)
+			switch d.DB {
+			case "net":
+				writeResult(w, rrd{Data: []map[string]int64{{"time": 1600000000, "rate_down": 1000}}})
+			case "dsl":
+				writeResult(w, rrd{Data: []map[string]int64{{"time": 1600000000, "rate_down": 2000}}})
+			}
+		default:
+			writeError(w, "internal_error")
+		}
+	}))
+	defer ts.Close()
+
+	cfg := defaultConfig()
+	cfg.Collectors = map[string]collectorConfig{}
+	for name := range collectorFactories {
+		if name != "rrd" {
+			cfg.setEnabled(name, false)
+		}
+	}
+	cfg.Collectors["xdsl"] = collectorConfig{}
+	cfg.RRD = []rrdMetric{
+		{DB: "net", Field: "rate_down", Name: "freebox_net_down_bytes", Help: "Download rate"},
+		{DB: "dsl", Field: "rate_down", Name: "freebox_dsl_down_bytes", Help: "Available download bandwidth"},
+	}
+	net := `
+# HELP freebox_net_down_bytes Download rate
+# TYPE freebox_net_down_bytes gauge
+freebox_net_down_bytes 1000 1600000000000
+`
+	dsl := `
+# HELP freebox_dsl_down_bytes Available download bandwidth
+# TYPE freebox_dsl_down_bytes gauge
+freebox_dsl_down_bytes 2000 1600000000000
+`
+
+	// the dsl database is left out on a fiber connection
+	f := newFreeboxCollector(newTestClient(ts.URL), cfg)
+	err := testutil.CollectAndCompare(f, strings.NewReader(net), "freebox_net_down_bytes", "freebox_dsl_down_bytes")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// and read on a DSL one
+	media = "xdsl"
+	err = testutil.CollectAndCompare(f, strings.NewReader(net+dsl), "freebox_net_down_bytes", "freebox_dsl_down_bytes")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// unless the xdsl collector is turned off
+	cfg.setEnabled("xdsl", false)
+	f = newFreeboxCollector(newTestClient(ts.URL), cfg)
+	err = testutil.CollectAndCompare(f, strings.NewReader(net), "freebox_net_down_bytes", "freebox_dsl_down_bytes")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	// or forced on, whatever the media
+	media = "ftth"
+	cfg.setEnabled("xdsl", true)
+	f = newFreeboxCollector(newTestClient(ts.URL), cfg)
+	err = testutil.CollectAndCompare(f, strings.NewReader(net+dsl), "freebox_net_down_bytes", "freebox_dsl_down_bytes")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// envPrefix prefixes the environment variables overriding the configuration
+const envPrefix = "FREEBOX_EXPORTER_"
+
+// labelNameRE matches the valid Prometheus label names
+var labelNameRE = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// config holds every setting of the exporter, read from the configuration
+// file, then overridden by the environment and by the command line flags
+type config struct {
+	Listen       string                     `yaml:"listen"`
+	Debug        bool                       `yaml:"debug"`
+	Timeout      time.Duration              `yaml:"timeout"`
+	StaleGrace   time.Duration              `yaml:"stale_grace,omitempty"`
+	CompatGauges bool                       `yaml:"compat_gauges,omitempty"`
+	App          app                        `yaml:"app"`
+	Boxes        []boxConfig                `yaml:"boxes"`
+	Collectors   map[string]collectorConfig `yaml:"collectors,omitempty"`
+	Labels       labelConfig                `yaml:"labels,omitempty"`
+	RRD          []rrdMetric                `yaml:"rrd,omitempty"`
+}
+
+// boxConfig describes how to reach a Freebox and where its app_token is kept
+type boxConfig struct {
+	Name     string `yaml:"name,omitempty"`
+	Endpoint string `yaml:"endpoint,omitempty"`
+	UID      string `yaml:"uid,omitempty"`
+	Token    string `yaml:"token,omitempty"`
+	HTTPS    bool   `yaml:"https,omitempty"`
+	Pin      string `yaml:"pin,omitempty"`
+}
+
+// collectorConfig tunes a single collector: a collector with an interval
+// is fetched at most once per interval and its last metrics are served
+// in between, a collector with a timeout gets its own deadline
+type collectorConfig struct {
+	Enabled  *bool         `yaml:"enabled,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+}
+
+// labelConfig is the label policy applied to every metric of the boxes
+type labelConfig struct {
+	Const map[string]string `yaml:"const,omitempty"`
+	Drop  []string          `yaml:"drop,omitempty"`
+}
+
+// defaultConfig returns the settings used without a configuration file
+func defaultConfig() *config {
+	return &config{
+		Listen:  ":10001",
+		Timeout: 10 * time.Second,
+		App: app{
+			AppID:      "fr.freebox.exporter",
+			AppName:    "prometheus-exporter",
+			AppVersion: "0.4",
+			DeviceName: "local",
+		},
+		RRD: append([]rrdMetric{}, rrdMetrics...),
+	}
+}
+
+// loadConfig reads the configuration file at path, expanding the
+// ${VARIABLES} it contains, and applies the environment overrides
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), cfg); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	// without any box the exporter monitors the Freebox of the LAN
+	if len(cfg.Boxes) == 0 {
+		cfg.Boxes = []boxConfig{{}}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides the settings with the FREEBOX_EXPORTER_* variables,
+// the ones about the box only apply when a single box is configured
+func (cfg *config) applyEnv() error {
+	texts := map[string]*string{
+		"LISTEN":          &cfg.Listen,
+		"APP_ID":          &cfg.App.AppID,
+		"APP_NAME":        &cfg.App.AppName,
+		"APP_VERSION":     &cfg.App.AppVersion,
+		"APP_DEVICE_NAME": &cfg.App.DeviceName,
+	}
+	if len(cfg.Boxes) == 1 {
+		texts["ENDPOINT"] = &cfg.Boxes[0].Endpoint
+		texts["UID"] = &cfg.Boxes[0].UID
+		texts["TOKEN"] = &cfg.Boxes[0].Token
+		texts["PIN"] = &cfg.Boxes[0].Pin
+	}
+	for name, value := range texts {
+		if v, ok := os.LookupEnv(envPrefix + name); ok {
+			*value = v
+		}
+	}
+
+	bools := map[string]*bool{
+		"DEBUG":         &cfg.Debug,
+		"COMPAT_GAUGES": &cfg.CompatGauges,
+	}
+	if len(cfg.Boxes) == 1 {
+		bools["HTTPS"] = &cfg.Boxes[0].HTTPS
+	}
+	for name, value := range bools {
+		if v, ok := os.LookupEnv(envPrefix + name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", envPrefix, name, err)
+			}
+			*value = b
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"TIMEOUT":     &cfg.Timeout,
+		"STALE_GRACE": &cfg.StaleGrace,
+	}
+	for name, value := range durations {
+		if v, ok := os.LookupEnv(envPrefix + name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", envPrefix, name, err)
+			}
+			*value = d
+		}
+	}
+	return nil
+}
+
+// validate checks the settings once every override has been applied
+func (cfg *config) validate() error {
+	if cfg.Listen == "" {
+		return errors.New("no listen address")
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("the timeout must be positive")
+	}
+	if cfg.StaleGrace < 0 {
+		return errors.New("the stale grace period cannot be negative")
+	}
+	if cfg.App.AppID == "" {
+		return errors.New("no app id")
+	}
+
+	if len(cfg.Boxes) == 0 {
+		return errors.New("no box configured")
+	}
+	names := map[string]bool{}
+	for i, b := range cfg.Boxes {
+		if b.Name == "" && len(cfg.Boxes) > 1 {
+			return fmt.Errorf("box #%d has no name", i+1)
+		}
+		if names[b.Name] {
+			return fmt.Errorf("box %q is configured twice", b.Name)
+		}
+		names[b.Name] = true
+
+		if b.Token != "" {
+			if _, err := newTokenStore(b.Token); err != nil {
+				return fmt.Errorf("box %q: %v", b.Name, err)
+			}
+		}
+	}
+
+	for name, c := range cfg.Collectors {
+		if _, ok := collectorFactories[name]; !ok {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+		if c.Interval < 0 || c.Timeout < 0 {
+			return fmt.Errorf("collector %q: the interval and the timeout cannot be negative", name)
+		}
+		// a collector runs within the deadline of the whole scrape
+		if c.Timeout > cfg.Timeout {
+			return fmt.Errorf("collector %q: the timeout cannot exceed the timeout of the scrape, %s", name, cfg.Timeout)
+		}
+	}
+
+	for name := range cfg.Labels.Const {
+		if !labelNameRE.MatchString(name) || name == "box" {
+			return fmt.Errorf("invalid constant label %q", name)
+		}
+	}
+	for _, name := range cfg.Labels.Drop {
+		if !labelNameRE.MatchString(name) {
+			return fmt.Errorf("invalid label %q to drop", name)
+		}
+	}
+
+	// the fields sharing a metric name must agree on its help and labels
+	seen := map[string]rrdMetric{}
+	for i, m := range cfg.RRD {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("rrd #%d: %v", i+1, err)
+		}
+		first, ok := seen[m.fullName()]
+		if !ok {
+			seen[m.fullName()] = m
+			continue
+		}
+		if first.Help != m.Help || first.valueType() != m.valueType() ||
+			strings.Join(first.labelNames(), ",") != strings.Join(m.labelNames(), ",") {
+			return fmt.Errorf("rrd #%d: %q differs from its first definition", i+1, m.fullName())
+		}
+	}
+	return nil
+}
+
+// multiBox tells whether the boxes are told apart by a box label
+func (cfg *config) multiBox() bool {
+	for _, b := range cfg.Boxes {
+		if b.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// box returns the configuration of the box called name, or the only
+// box configured when name is empty
+func (cfg *config) box(name string) (boxConfig, error) {
+	if name == "" && len(cfg.Boxes) == 1 {
+		return cfg.Boxes[0], nil
+	}
+	if name == "" {
+		return boxConfig{}, errors.New("several boxes are configured, use -box to pick one")
+	}
+	for _, b := range cfg.Boxes {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return boxConfig{}, fmt.Errorf("no box called %q in the configuration", name)
+}
+
+// enabled tells whether the collector called name runs, all of them do
+// unless disabled in the configuration
+func (cfg *config) enabled(name string) bool {
+	c, ok := cfg.Collectors[name]
+	return !ok || c.Enabled == nil || *c.Enabled
+}
+
+// setEnabled turns the collector called name on or off, which also
+// forces a line collector to run whatever the WAN media
+func (cfg *config) setEnabled(name string, enabled bool) {
+	if cfg.Collectors == nil {
+		cfg.Collectors = map[string]collectorConfig{}
+	}
+	c := cfg.Collectors[name]
+	c.Enabled = &enabled
+	cfg.Collectors[name] = c
+}
+
+// registerer wraps reg to add the constant labels, and the box label
+// when boxLabel is not empty, to the metrics of a box
+func (cfg *config) registerer(reg prometheus.Registerer, boxLabel string) prometheus.Registerer {
+	labels := prometheus.Labels{}
+	for name, value := range cfg.Labels.Const {
+		labels[name] = value
+	}
+	if boxLabel != "" {
+		labels["box"] = boxLabel
+	}
+	if len(labels) == 0 {
+		return reg
+	}
+	return prometheus.WrapRegistererWith(labels, reg)
+}
+
+// tokenStore returns the store of the app_token of the box, by default
+// a file in $HOME named after the box
+func (b boxConfig) tokenStore() (tokenStore, error) {
+	spec := b.Token
+	if spec == "" {
+		spec = "file:" + os.Getenv("HOME") + "/.freebox_token"
+		if b.Name != "" {
+			spec += "." + b.Name
+		}
+	}
+	return newTokenStore(spec)
+}
+
+// newBoxClient discovers the API of the box and returns a client with
+// its own session, ready to be authorized or scraped
+func newBoxClient(b boxConfig, myApp app) (*client, error) {
+	myStore, err := b.tokenStore()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+		boxes, err := browse(3 * time.Second)
+		if err == nil {
+			var found box
+			found, err = findBox(boxes, b.UID)
+			if err == nil {
+				endpoint = found.endpoint()
+			}
+		}
+		if err != nil {
+			log.Printf("An error occured while looking for the Freebox over mDNS, falling back to %s: %v", endpoint, err)
+		}
+	}
+
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint = endpoint + "/"
+	}
+
+	myAuthInfo := &authInfo{
+		myStore: myStore,
+		myApp:   myApp,
+	}
+	myClient := newClient(endpoint, myAuthInfo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if err := myClient.discover(ctx); err != nil {
+		log.Printf("An error occured while discovering the Freebox API at %s, falling back to v%d: %v", endpoint, myClient.version.major(), err)
+	}
+	cancel()
+
+	if b.UID != "" && myClient.version.UID != "" && myClient.version.UID != b.UID {
+		return nil, fmt.Errorf("%s is the Freebox %q, not %q", endpoint, myClient.version.UID, b.UID)
+	}
+
+	if b.HTTPS {
+		if err := myClient.enableHTTPS(b.Pin); err != nil {
+			return nil, err
+		}
+		log.Println("reaching the Freebox over HTTPS at", myClient.endpoint)
+	}
+
+	myAuthInfo.myEndpoint = myClient.endpoint
+	myAuthInfo.myUID = myClient.version.UID
+
+	login := myClient.url(myClient.version.major(), "login/")
+	myAuthInfo.myAPI = api{
+		login:        login,
+		authz:        login + "authorize/",
+		loginSession: login + "session/",
+	}
+	return myClient, nil
+}
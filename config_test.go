@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "freebox_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`
+listen: ":9999"
+app:
+  id: fr.freebox.monitoring
+  version: "1.0"
+boxes:
+  - name: paris
+    endpoint: http://192.168.1.254/
+    token: secret:${FREEBOX_SECRETS}/paris
+  - name: lyon
+    uid: 0123456789abcdef
+    https: true
+collectors:
+  xdsl:
+    enabled: false
+  wifi:
+    interval: 5m
+    timeout: 15s
+labels:
+  const:
+    site: home
+  drop: [vendor]
+`)
+	f.Close()
+
+	os.Setenv("FREEBOX_SECRETS", "/etc/freebox")
+	os.Setenv("FREEBOX_EXPORTER_TIMEOUT", "20s")
+	defer os.Unsetenv("FREEBOX_SECRETS")
+	defer os.Unsetenv("FREEBOX_EXPORTER_TIMEOUT")
+
+	cfg, err := loadConfig(f.Name())
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if cfg.Listen != ":9999" {
+		t.Error("Expected :9999, but got", cfg.Listen)
+	}
+
+	if cfg.Timeout != 20*time.Second {
+		t.Error("Expected 20s, but got", cfg.Timeout)
+	}
+
+	if cfg.App.AppID != "fr.freebox.monitoring" || cfg.App.AppName != "prometheus-exporter" {
+		t.Error("Expected fr.freebox.monitoring and the default name, but got", cfg.App)
+	}
+
+	if len(cfg.Boxes) != 2 || !cfg.multiBox() {
+		t.Fatal("Expected 2 boxes, but got", cfg.Boxes)
+	}
+
+	if cfg.enabled("xdsl") || !cfg.enabled("wifi") || !cfg.enabled("lan") {
+		t.Error("Expected only xdsl to be disabled, but got", cfg.Collectors)
+	}
+
+	if cfg.Collectors["wifi"].Interval != 5*time.Minute {
+		t.Error("Expected 5m, but got", cfg.Collectors["wifi"].Interval)
+	}
+
+	b, err := cfg.box("lyon")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+	if b.UID != "0123456789abcdef" || !b.HTTPS {
+		t.Error("Expected the lyon box, but got", b)
+	}
+
+	_, err = cfg.box("")
+	if err == nil {
+		t.Error("Expected an error as several boxes are configured")
+	}
+
+	_, err = cfg.box("marseille")
+	if err.Error() != `no box called "marseille" in the configuration` {
+		t.Error(`Expected no box called "marseille" in the configuration, but got`, err)
+	}
+
+	s, err := cfg.Boxes[0].tokenStore()
+	if err != nil || s.String() != "secret:/etc/freebox/paris" {
+		t.Error("Expected secret:/etc/freebox/paris, but got", s, err)
+	}
+
+	s, err = cfg.Boxes[1].tokenStore()
+	if err != nil || s.String() != "file:"+os.Getenv("HOME")+"/.freebox_token.lyon" {
+		t.Error("Expected a token file named after the box, but got", s, err)
+	}
+}
+
+func TestLoadDefaultConfig(t *testing.T) {
+	os.Setenv("FREEBOX_EXPORTER_ENDPOINT", "http://192.168.1.254/")
+	os.Setenv("FREEBOX_EXPORTER_HTTPS", "yes")
+	defer os.Unsetenv("FREEBOX_EXPORTER_ENDPOINT")
+	defer os.Unsetenv("FREEBOX_EXPORTER_HTTPS")
+
+	_, err := loadConfig("")
+	if err.Error() != `FREEBOX_EXPORTER_HTTPS: strconv.ParseBool: parsing "yes": invalid syntax` {
+		t.Error("Expected an invalid FREEBOX_EXPORTER_HTTPS, but got", err)
+	}
+
+	os.Setenv("FREEBOX_EXPORTER_HTTPS", "true")
+	cfg, err := loadConfig("")
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if len(cfg.Boxes) != 1 || cfg.multiBox() {
+		t.Fatal("Expected a single box, but got", cfg.Boxes)
+	}
+
+	if cfg.Boxes[0].Endpoint != "http://192.168.1.254/" || !cfg.Boxes[0].HTTPS {
+		t.Error("Expected the box of the environment, but got", cfg.Boxes[0])
+	}
+
+	s, err := cfg.Boxes[0].tokenStore()
+	if err != nil || s.String() != "file:"+os.Getenv("HOME")+"/.freebox_token" {
+		t.Error("Expected the default token file, but got", s, err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for expected, modify := range map[string]func(cfg *config){
+		"no listen address":                         func(cfg *config) { cfg.Listen = "" },
+		"the timeout must be positive":              func(cfg *config) { cfg.Timeout = 0 },
+		"the stale grace period cannot be negative": func(cfg *config) { cfg.StaleGrace = -time.Minute },
+		"no box configured":                         func(cfg *config) { cfg.Boxes = nil },
+		"box #1 has no name":                        func(cfg *config) { cfg.Boxes = []boxConfig{{}, {Name: "paris"}} },
+		`box "paris" is configured twice`:           func(cfg *config) { cfg.Boxes = []boxConfig{{Name: "paris"}, {Name: "paris"}} },
+		`box "paris": unknown token store "vault", expected file, secret or env`: func(cfg *config) {
+			cfg.Boxes = []boxConfig{{Name: "paris", Token: "vault:freebox"}}
+		},
+		`unknown collector "dns"`: func(cfg *config) {
+			cfg.Collectors = map[string]collectorConfig{"dns": {}}
+		},
+		`collector "lan": the interval and the timeout cannot be negative`: func(cfg *config) {
+			cfg.Collectors = map[string]collectorConfig{"lan": {Interval: -time.Second}}
+		},
+		`collector "wifi": the timeout cannot exceed the timeout of the scrape, 10s`: func(cfg *config) {
+			cfg.Collectors = map[string]collectorConfig{"wifi": {Timeout: 30 * time.Second}}
+		},
+		`invalid constant label "box"`: func(cfg *config) {
+			cfg.Labels.Const = map[string]string{"box": "paris"}
+		},
+		`invalid label "mac-address" to drop`: func(cfg *config) {
+			cfg.Labels.Drop = []string{"mac-address"}
+		},
+		"rrd #1: the db, the field and the name are required": func(cfg *config) {
+			cfg.RRD = []rrdMetric{{DB: "temp", Name: "freebox_temp"}}
+		},
+		`rrd #1: the counter "freebox_net_rx_bytes" must end with _total`: func(cfg *config) {
+			cfg.RRD = []rrdMetric{{DB: "net", Field: "rx", Name: "freebox_net_rx", Unit: "bytes", Type: "counter"}}
+		},
+		`rrd #2: "freebox_temp_celsius" differs from its first definition`: func(cfg *config) {
+			cfg.RRD = []rrdMetric{
+				{DB: "temp", Field: "cpum", Name: "freebox_temp", Unit: "celsius", Labels: map[string]string{"sensor": "cpum"}},
+				{DB: "temp", Field: "hdd", Name: "freebox_temp_celsius"},
+			}
+		},
+	} {
+		cfg := defaultConfig()
+		cfg.Boxes = []boxConfig{{}}
+		modify(cfg)
+
+		err := cfg.validate()
+		if err == nil || err.Error() != expected {
+			t.Error("Expected", expected, "but got", err)
+		}
+	}
+}
@@ -2,248 +2,888 @@ package main
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 var (
 	// XXX: see https://dev.freebox.fr/sdk/os/ for API documentation
 	// XXX: see https://prometheus.io/docs/practices/naming/ for metric names
 
+	// scrape
+	scrapeDurationDesc = prometheus.NewDesc(
+		"freebox_scrape_collector_duration_seconds",
+		"Duration of a collector scrape (in seconds)",
+		[]string{
+			"collector",
+		},
+		nil,
+	)
+	scrapeSuccessDesc = prometheus.NewDesc(
+		"freebox_scrape_collector_success",
+		"Whether a collector succeeded",
+		[]string{
+			"collector",
+		},
+		nil,
+	)
+
+	// api_version
+	apiInfoDesc = prometheus.NewDesc(
+		"freebox_api_info",
+		"Freebox model and API version discovered at startup",
+		[]string{
+			"box_model",
+			"box_model_name",
+			"device_type",
+			"api_version",
+		},
+		nil,
+	)
+
+	// connection
+	connectionStateDesc = prometheus.NewDesc(
+		"freebox_connection_state",
+		"State of the WAN connection",
+		[]string{
+			"state", // up|going_up|down|going_down
+		},
+		nil,
+	)
+	connectionInfoDesc = prometheus.NewDesc(
+		"freebox_connection_info",
+		"Type, media and IP addresses of the WAN connection",
+		[]string{
+			"type",
+			"media",
+			"ipv4",
+			"ipv6",
+		},
+		nil,
+	)
+	connectionBandwidthDesc = prometheus.NewDesc(
+		"freebox_connection_bandwidth_bits_per_second",
+		"Available bandwidth of the WAN connection (in bit/s)",
+		[]string{
+			"direction", // up|down
+		},
+		nil,
+	)
+	connectionRateDesc = prometheus.NewDesc(
+		"freebox_connection_rate_bytes_per_second",
+		"Current rate of the WAN connection (in byte/s)",
+		[]string{
+			"direction", // up|down
+		},
+		nil,
+	)
+	connectionBytesDesc = prometheus.NewDesc(
+		"freebox_connection_bytes_total",
+		"Bytes transferred over the WAN connection",
+		[]string{
+			"direction", // up|down
+		},
+		nil,
+	)
+
+	// connectionConfig
+	connectionConfigInfoDesc = prometheus.NewDesc(
+		"freebox_connection_config_info",
+		"Configuration of the WAN connection",
+		[]string{
+			"ping",
+			"wol",
+			"adblock",
+			"remote_access",
+			"remote_access_port",
+			"api_remote_access",
+			"allow_token_request",
+			"is_secure_pass",
+		},
+		nil,
+	)
+	connectionIPv6InfoDesc = prometheus.NewDesc(
+		"freebox_connection_ipv6_info",
+		"IPv6 configuration of the WAN connection",
+		[]string{
+			"enabled",
+			"firewall",
+			"link_local",
+		},
+		nil,
+	)
+	connectionIPv6DelegationInfoDesc = prometheus.NewDesc(
+		"freebox_connection_ipv6_delegation_info",
+		"IPv6 prefix delegated to a next hop",
+		[]string{
+			"prefix",
+			"next_hop",
+		},
+		nil,
+	)
+	ddnsStatusInfoDesc = prometheus.NewDesc(
+		"freebox_connection_ddns_status_info",
+		"Status of a DynDNS provider",
+		[]string{
+			"provider",
+			"status",
+		},
+		nil,
+	)
+	ddnsLastRefreshDesc = prometheus.NewDesc(
+		"freebox_connection_ddns_last_refresh_timestamp_seconds",
+		"Last successful refresh of a DynDNS provider",
+		[]string{
+			"provider",
+		},
+		nil,
+	)
+	ddnsLastErrorDesc = prometheus.NewDesc(
+		"freebox_connection_ddns_last_error_timestamp_seconds",
+		"Last failed refresh of a DynDNS provider",
+		[]string{
+			"provider",
+		},
+		nil,
+	)
+	ddnsNextRetryDesc = prometheus.NewDesc(
+		"freebox_connection_ddns_next_retry_timestamp_seconds",
+		"Next refresh attempt of a DynDNS provider",
+		[]string{
+			"provider",
+		},
+		nil,
+	)
+
+	// connectionFtth
+	connectionFtthSfpPresentDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_present", "Whether the SFP is plugged in", nil, nil,
+	)
+	connectionFtthSfpAlimOkDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_alim_ok", "Whether the SFP power supply is ok", nil, nil,
+	)
+	connectionFtthSfpHasPowerReportDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_has_power_report", "Whether the SFP reports its optical power", nil, nil,
+	)
+	connectionFtthSfpHasSignalDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_has_signal", "Whether the SFP detects a signal", nil, nil,
+	)
+	connectionFtthLinkDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_link", "Whether the FTTH link is up", nil, nil,
+	)
+	connectionFtthRxPowerDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_rx_power_dbm", "Optical power received by the SFP (in dBm)", nil, nil,
+	)
+	connectionFtthTxPowerDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_tx_power_dbm", "Optical power transmitted by the SFP (in dBm)", nil, nil,
+	)
+	connectionFtthSfpInfoDesc = prometheus.NewDesc(
+		"freebox_connection_ftth_sfp_info",
+		"SFP model, vendor and serial number",
+		[]string{
+			"model",
+			"vendor",
+			"serial",
+		},
+		nil,
+	)
+
 	// connectionXdsl
-	connectionXdslStatusUptimeGauges = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "freebox_connection_xdsl_status_uptime_seconds_total",
-	},
+	connectionXdslStatusUptimeDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_status_uptime_seconds_total",
+		"xDSL link uptime (in seconds)",
 		[]string{
 			"status",
 			"protocol",
 			"modulation",
 		},
+		nil,
 	)
 
-	connectionXdslDownAttnGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_connection_xdsl_down_attn_decibels",
-	})
-	connectionXdslUpAttnGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_connection_xdsl_up_attn_decibels",
-	})
-	connectionXdslDownSnrGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_connection_xdsl_down_snr_decibels",
-	})
-	connectionXdslUpSnrGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_connection_xdsl_up_snr_decibels",
-	})
+	connectionXdslDownAttnDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_down_attn_decibels", "Download line attenuation (in dB)", nil, nil,
+	)
+	connectionXdslUpAttnDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_up_attn_decibels", "Upload line attenuation (in dB)", nil, nil,
+	)
+	connectionXdslDownSnrDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_down_snr_decibels", "Download signal/noise ratio (in dB)", nil, nil,
+	)
+	connectionXdslUpSnrDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_up_snr_decibels", "Upload signal/noise ratio (in dB)", nil, nil,
+	)
 
-	connectionXdslErrorGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_connection_xdsl_errors_total",
-			Help: "Error counts",
-		},
+	connectionXdslErrorDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_errors_total",
+		"Error counts",
 		[]string{
 			"direction", // up|down
 			"name",      // crc|es|fec|hec
 		},
+		nil,
 	)
 
-	connectionXdslGinpGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_connection_xdsl_ginp",
-		},
+	connectionXdslGinpDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_ginp",
+		"G.INP status and retransmission counts",
 		[]string{
 			"direction", // up|down
 			"name",      // enabled|rtx_(tx|c|uc)
 		},
+		nil,
 	)
 
-	connectionXdslNitroGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_connection_xdsl_nitro",
-		},
+	connectionXdslNitroDesc = prometheus.NewDesc(
+		"freebox_connection_xdsl_nitro",
+		"Whether Nitro is enabled",
 		[]string{
 			"direction", // up|down
 		},
+		nil,
 	)
 
-	// RRD dsl [unstable]
-	rateUpGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_dsl_up_bytes",
-		Help: "Available upload bandwidth (in byte/s)",
-	})
-	rateDownGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_dsl_down_bytes",
-		Help: "Available download bandwidth (in byte/s)",
-	})
-	snrUpGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_dsl_snr_up_decibel",
-		Help: "Upload signal/noise ratio (in 1/10 dB)",
-	})
-	snrDownGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_dsl_snr_down_decibel",
-		Help: "Download signal/noise ratio (in 1/10 dB)",
-	})
-
 	// freeplug
-	freeplugRxRateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "freebox_freeplug_rx_rate_bits",
-		Help: "rx rate (from the freeplugs to the \"cco\" freeplug) (in bits/s) -1 if not available",
-	},
+	freeplugRxRateDesc = prometheus.NewDesc(
+		"freebox_freeplug_rx_rate_bits",
+		"rx rate (from the freeplugs to the \"cco\" freeplug) (in bits/s) -1 if not available",
 		[]string{
 			"id",
 		},
+		nil,
 	)
-	freeplugTxRateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "freebox_freeplug_tx_rate_bits",
-		Help: "tx rate (from the \"cco\" freeplug to the freeplugs) (in bits/s) -1 if not available",
-	},
+	freeplugTxRateDesc = prometheus.NewDesc(
+		"freebox_freeplug_tx_rate_bits",
+		"tx rate (from the \"cco\" freeplug to the freeplugs) (in bits/s) -1 if not available",
 		[]string{
 			"id",
 		},
+		nil,
 	)
-	freeplugHasNetworkGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "freebox_freeplug_has_network",
-		Help: "is connected to the network",
-	},
+	freeplugHasNetworkDesc = prometheus.NewDesc(
+		"freebox_freeplug_has_network",
+		"is connected to the network",
 		[]string{
 			"id",
 		},
+		nil,
 	)
 
-	// RRD Net [unstable]
-	bwUpGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_net_bw_up_bytes",
-		Help: "Upload available bandwidth (in byte/s)",
-	})
-	bwDownGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_net_bw_down_bytes",
-		Help: "Download available bandwidth (in byte/s)",
-	})
-	netRateUpGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_net_up_bytes",
-		Help: "Upload rate (in byte/s)",
-	})
-	netRateDownGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_net_down_bytes",
-		Help: "Download rate (in byte/s)",
-	})
-	vpnRateUpGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_net_vpn_up_bytes",
-		Help: "Vpn client upload rate (in byte/s)",
-	})
-	vpnRateDownGauge = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "freebox_net_vpn_down_bytes",
-		Help: "Vpn client download rate (in byte/s)",
-	})
+	// dhcp
+	dhcpEnabledDesc = prometheus.NewDesc(
+		"freebox_dhcp_enabled",
+		"Whether the DHCP server is enabled",
+		[]string{
+			"family", // ipv4|ipv6
+		},
+		nil,
+	)
+	dhcpConfigInfoDesc = prometheus.NewDesc(
+		"freebox_dhcp_config_info",
+		"Configuration of the DHCP server",
+		[]string{
+			"gateway",
+			"netmask",
+			"range_start",
+			"range_end",
+			"sticky_assign",
+			"always_broadcast",
+			"dns",
+		},
+		nil,
+	)
+	dhcpPoolSizeDesc = prometheus.NewDesc(
+		"freebox_dhcp_pool_size",
+		"Number of addresses in the dynamic range of the DHCP server",
+		nil,
+		nil,
+	)
+	dhcpPoolLeasesDesc = prometheus.NewDesc(
+		"freebox_dhcp_pool_leases",
+		"Number of leases in the dynamic range of the DHCP server",
+		nil,
+		nil,
+	)
+	dhcpPoolUtilizationDesc = prometheus.NewDesc(
+		"freebox_dhcp_pool_utilization_ratio",
+		"Share of the dynamic range of the DHCP server which is leased",
+		nil,
+		nil,
+	)
+	dhcpLeasesDesc = prometheus.NewDesc(
+		"freebox_dhcp_leases",
+		"Number of leases of the DHCP server",
+		[]string{
+			"family",
+			"type", // static|dynamic
+		},
+		nil,
+	)
+	dhcpLeaseExpiryDesc = prometheus.NewDesc(
+		"freebox_dhcp_lease_expiry_seconds",
+		"Seconds until a dynamic lease expires",
+		[]string{
+			"family",
+			"mac",
+			"ip",
+			"hostname",
+		},
+		nil,
+	)
+	dhcpStaticLeaseOnlineDesc = prometheus.NewDesc(
+		"freebox_dhcp_static_lease_online",
+		"Whether the host of a static lease is reachable",
+		[]string{
+			"family",
+			"mac",
+			"ip",
+			"hostname",
+		},
+		nil,
+	)
 
-	// Lan
-	lanReachableGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_lan_reachable",
-			Help: "Hosts reachable on LAN",
+	// fw
+	fwRedirInfoDesc = prometheus.NewDesc(
+		"freebox_fw_redir_info",
+		"Enabled port forwarding",
+		[]string{
+			"id",
+			"proto", // tcp|udp
+			"wan_port_start",
+			"wan_port_end",
+			"lan_ip",
+			"lan_port",
+			"src_ip",
+			"comment",
 		},
+		nil,
+	)
+	fwIncomingInfoDesc = prometheus.NewDesc(
+		"freebox_fw_incoming_info",
+		"Enabled incoming port of a Freebox service",
+		[]string{
+			"id",
+			"proto", // tcp|udp|tcp_udp
+			"port",
+		},
+		nil,
+	)
+	fwDmzEnabledDesc = prometheus.NewDesc(
+		"freebox_fw_dmz_enabled",
+		"Whether the DMZ is enabled",
 		[]string{
-			"name", // hostname
-			"vendor",
 			"ip",
 		},
+		nil,
+	)
+	fwWanPortsDesc = prometheus.NewDesc(
+		"freebox_fw_wan_ports",
+		"Number of WAN ports forwarded to the LAN or opened for a Freebox service",
+		[]string{
+			"source", // redir|incoming|upnp
+		},
+		nil,
+	)
+	upnpRedirInfoDesc = prometheus.NewDesc(
+		"freebox_upnpigd_redir_info",
+		"Enabled UPnP IGD redirection",
+		[]string{
+			"proto",
+			"ext_port",
+			"ext_src_ip",
+			"int_ip",
+			"int_port",
+			"description",
+		},
+		nil,
+	)
+	upnpRedirRemainingDesc = prometheus.NewDesc(
+		"freebox_upnpigd_redir_remaining_seconds",
+		"Seconds until a UPnP IGD redirection expires",
+		[]string{
+			"proto",
+			"ext_port",
+		},
+		nil,
+	)
+	upnpRedirsDesc = prometheus.NewDesc(
+		"freebox_upnpigd_redirs",
+		"Number of enabled UPnP IGD redirections",
+		nil,
+		nil,
 	)
 
-	systemTempGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_system_temp_celsius",
-			Help: "Temperature sensors reported by system (in °C)",
+	// switch
+	switchPortLinkDesc = prometheus.NewDesc(
+		"freebox_switch_port_link",
+		"Whether the link of a switch port is up",
+		[]string{
+			"port",
 		},
+		nil,
+	)
+	switchPortSpeedDesc = prometheus.NewDesc(
+		"freebox_switch_port_speed_bits_per_second",
+		"Negotiated speed of a switch port (in bit/s)",
 		[]string{
-			"name",
+			"port",
+		},
+		nil,
+	)
+	switchPortInfoDesc = prometheus.NewDesc(
+		"freebox_switch_port_info",
+		"Negotiated mode and duplex of a switch port",
+		[]string{
+			"port",
+			"mode",
+			"duplex", // half|full
+		},
+		nil,
+	)
+	switchPortBytesDesc = prometheus.NewDesc(
+		"freebox_switch_port_bytes_total",
+		"Bytes transferred by a switch port",
+		[]string{
+			"port",
+			"direction", // rx|tx
+		},
+		nil,
+	)
+	switchPortPacketsDesc = prometheus.NewDesc(
+		"freebox_switch_port_packets_total",
+		"Packets transferred by a switch port",
+		[]string{
+			"port",
+			"direction", // rx|tx
+		},
+		nil,
+	)
+	switchPortErrorsDesc = prometheus.NewDesc(
+		"freebox_switch_port_errors_total",
+		"Packets in error on a switch port",
+		[]string{
+			"port",
+			"direction", // rx|tx
+		},
+		nil,
+	)
+	switchPortCollisionsDesc = prometheus.NewDesc(
+		"freebox_switch_port_collisions_total",
+		"Collisions on a switch port",
+		[]string{
+			"port",
 		},
+		nil,
 	)
 
-	systemFanGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_system_fan_rpm",
-			Help: "Fan speed reported by system (in RPM)",
+	switchPortHostInfoDesc = prometheus.NewDesc(
+		"freebox_switch_port_host_info",
+		"Host seen behind a switch port, named after the LAN browser",
+		[]string{
+			"port",
+			"mac",
+			"hostname",
+		},
+		nil,
+	)
+
+	// Lan
+	lanReachableDesc = prometheus.NewDesc(
+		"freebox_lan_reachable",
+		"Hosts reachable on LAN",
+		[]string{
+			"name", // hostname
+			"vendor",
+			"ip",
+		},
+		nil,
+	)
+	lanHostInfoDesc = prometheus.NewDesc(
+		"freebox_lan_host_info",
+		"Host known to the LAN browser",
+		[]string{
+			"interface", // pub|wifiguest
+			"mac",
+			"name",
+			"vendor",
+			"host_type",
+		},
+		nil,
+	)
+	lanHostActiveDesc = prometheus.NewDesc(
+		"freebox_lan_host_active",
+		"Whether the host is active on the LAN",
+		[]string{
+			"interface",
+			"mac",
+		},
+		nil,
+	)
+	lanHostLastActivityDesc = prometheus.NewDesc(
+		"freebox_lan_host_last_activity_timestamp_seconds",
+		"Last time the host sent traffic",
+		[]string{
+			"interface",
+			"mac",
+		},
+		nil,
+	)
+	lanHostFirstActivityDesc = prometheus.NewDesc(
+		"freebox_lan_host_first_activity_timestamp_seconds",
+		"First time the host was seen",
+		[]string{
+			"interface",
+			"mac",
+		},
+		nil,
+	)
+	lanHostLastReachableDesc = prometheus.NewDesc(
+		"freebox_lan_host_last_reachable_timestamp_seconds",
+		"Last time the host was reachable",
+		[]string{
+			"interface",
+			"mac",
+		},
+		nil,
+	)
+	lanHostAddressDesc = prometheus.NewDesc(
+		"freebox_lan_host_address_active",
+		"Whether an address of the host is active",
+		[]string{
+			"interface",
+			"mac",
+			"family", // ipv4|ipv6
+			"address",
+		},
+		nil,
+	)
+	lanInterfaceHostsDesc = prometheus.NewDesc(
+		"freebox_lan_interface_hosts",
+		"Number of hosts known on a LAN browser interface",
+		[]string{
+			"interface",
+		},
+		nil,
+	)
+	lanHostTypeHostsDesc = prometheus.NewDesc(
+		"freebox_lan_host_type_hosts",
+		"Number of hosts known on the LAN by type",
+		[]string{
+			"host_type",
 		},
+		nil,
+	)
+
+	systemTempDesc = prometheus.NewDesc(
+		"freebox_system_temp_celsius",
+		"Temperature sensors reported by system (in °C)",
 		[]string{
 			"name",
 		},
+		nil,
 	)
 
-	systemUptimeGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_system_uptime_seconds_total",
+	systemFanDesc = prometheus.NewDesc(
+		"freebox_system_fan_rpm",
+		"Fan speed reported by system (in RPM)",
+		[]string{
+			"name",
 		},
+		nil,
+	)
+
+	systemUptimeDesc = prometheus.NewDesc(
+		"freebox_system_uptime_seconds_total",
+		"Freebox Server uptime (in seconds)",
 		[]string{
 			"firmware_version",
 		},
+		nil,
 	)
 
-	// wifi
+	// wifi, the stations are keyed by MAC and named by freebox_wifi_station_info
 	wifiLabels = []string{
 		"access_point",
-		"hostname",
-		"state",
+		"mac",
 	}
 
-	wifiSignalGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_signal_attenuation_db",
-			Help: "Wifi signal attenuation in decibel",
+	wifiStationInfoDesc = prometheus.NewDesc(
+		"freebox_wifi_station_info",
+		"Hostname, band and standard of a Wi-Fi station",
+		[]string{
+			"access_point",
+			"mac",
+			"hostname",
+			"band",     // 2d4g|5g|6g|60g
+			"standard", // legacy|n|ac|ax
 		},
+		nil,
+	)
+
+	wifiSignalDesc = prometheus.NewDesc(
+		"freebox_wifi_signal_attenuation_db",
+		"Wifi signal attenuation in decibel",
 		wifiLabels,
+		nil,
 	)
 
-	wifiInactiveGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_inactive_duration_seconds",
-			Help: "Wifi inactive duration in seconds",
-		},
+	wifiInactiveDesc = prometheus.NewDesc(
+		"freebox_wifi_inactive_duration_seconds",
+		"Wifi inactive duration in seconds",
 		wifiLabels,
+		nil,
 	)
 
-	wifiConnectionDurationGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_connection_duration_seconds",
-			Help: "Wifi connection duration in seconds",
-		},
+	wifiConnectionDurationDesc = prometheus.NewDesc(
+		"freebox_wifi_connection_duration_seconds",
+		"Wifi connection duration in seconds",
 		wifiLabels,
+		nil,
 	)
 
-	wifiRXBytesGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_rx_bytes",
-			Help: "Wifi received data (from station to Freebox) in bytes",
-		},
+	wifiRXBytesDesc = prometheus.NewDesc(
+		"freebox_wifi_rx_bytes_total",
+		"Wifi received data (from station to Freebox) in bytes",
 		wifiLabels,
+		nil,
 	)
 
-	wifiTXBytesGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_tx_bytes",
-			Help: "Wifi transmitted data (from Freebox to station) in bytes",
-		},
+	wifiTXBytesDesc = prometheus.NewDesc(
+		"freebox_wifi_tx_bytes_total",
+		"Wifi transmitted data (from Freebox to station) in bytes",
 		wifiLabels,
+		nil,
 	)
 
-	wifiRXRateGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_rx_rate",
-			Help: "Wifi reception data rate (from station to Freebox) in bytes/seconds",
-		},
+	// former names of the bytes, sent with -compat.gauges
+	wifiRXBytesGaugeDesc = prometheus.NewDesc(
+		"freebox_wifi_rx_bytes",
+		"Wifi received data (from station to Freebox) in bytes, deprecated by freebox_wifi_rx_bytes_total",
 		wifiLabels,
+		nil,
 	)
 
-	wifiTXRateGauges = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "freebox_wifi_tx_rate",
-			Help: "Wifi transmission data rate (from Freebox to station) in bytes/seconds",
-		},
+	wifiTXBytesGaugeDesc = prometheus.NewDesc(
+		"freebox_wifi_tx_bytes",
+		"Wifi transmitted data (from Freebox to station) in bytes, deprecated by freebox_wifi_tx_bytes_total",
 		wifiLabels,
+		nil,
+	)
+
+	wifiRXRateDesc = prometheus.NewDesc(
+		"freebox_wifi_rx_rate",
+		"Wifi reception data rate (from station to Freebox) in bytes/seconds",
+		wifiLabels,
+		nil,
+	)
+
+	wifiTXRateDesc = prometheus.NewDesc(
+		"freebox_wifi_tx_rate",
+		"Wifi transmission data rate (from Freebox to station) in bytes/seconds",
+		wifiLabels,
+		nil,
+	)
+
+	wifiTXRetriesDesc = prometheus.NewDesc(
+		"freebox_wifi_station_tx_retries_total",
+		"Frames retransmitted to a Wi-Fi station",
+		wifiLabels,
+		nil,
+	)
+
+	wifiTXFailuresDesc = prometheus.NewDesc(
+		"freebox_wifi_station_tx_failures_total",
+		"Frames which could not be transmitted to a Wi-Fi station",
+		wifiLabels,
+		nil,
+	)
+
+	wifiStationLabels = []string{
+		"access_point",
+		"mac",
+		"direction", // rx|tx
+	}
+
+	wifiStationBandwidthDesc = prometheus.NewDesc(
+		"freebox_wifi_station_bandwidth_mhz",
+		"Channel width of the last frame exchanged with a Wi-Fi station",
+		wifiStationLabels,
+		nil,
+	)
+
+	wifiStationMCSDesc = prometheus.NewDesc(
+		"freebox_wifi_station_mcs",
+		"MCS index of the last frame exchanged with a Wi-Fi station",
+		wifiStationLabels,
+		nil,
+	)
+
+	wifiStationNSSDesc = prometheus.NewDesc(
+		"freebox_wifi_station_nss",
+		"Spatial streams of the last frame exchanged with a Wi-Fi station",
+		wifiStationLabels,
+		nil,
+	)
+
+	wifiStationPhyRateDesc = prometheus.NewDesc(
+		"freebox_wifi_station_phy_rate_bits_per_second",
+		"PHY rate of the last frame exchanged with a Wi-Fi station (in bit/s)",
+		wifiStationLabels,
+		nil,
+	)
+
+	wifiStationFlagDesc = prometheus.NewDesc(
+		"freebox_wifi_station_flag",
+		"Capabilities and state of a Wi-Fi station",
+		[]string{
+			"access_point",
+			"mac",
+			"flag", // vht|he|powersave|authorized
+		},
+		nil,
+	)
+
+	wifiApInfoDesc = prometheus.NewDesc(
+		"freebox_wifi_ap_info",
+		"Band and channel width of a Wi-Fi access point",
+		[]string{
+			"access_point",
+			"band", // 2d4g|5g|6g|60g
+			"channel_width",
+		},
+		nil,
+	)
+
+	wifiApStateDesc = prometheus.NewDesc(
+		"freebox_wifi_ap_state",
+		"State of the radio of a Wi-Fi access point",
+		[]string{
+			"access_point",
+			"state",
+		},
+		nil,
+	)
+
+	wifiApChannelDesc = prometheus.NewDesc(
+		"freebox_wifi_ap_channel",
+		"Channel in use by a Wi-Fi access point",
+		[]string{
+			"access_point",
+			"type", // primary|secondary
+		},
+		nil,
+	)
+
+	wifiApDfsEnabledDesc = prometheus.NewDesc(
+		"freebox_wifi_ap_dfs_enabled",
+		"Whether a Wi-Fi access point may use the DFS channels",
+		[]string{
+			"access_point",
+		},
+		nil,
+	)
+
+	wifiApDfsCacRemainingDesc = prometheus.NewDesc(
+		"freebox_wifi_ap_dfs_cac_remaining_seconds",
+		"Seconds left before a Wi-Fi access point may emit on a DFS channel",
+		[]string{
+			"access_point",
+		},
+		nil,
+	)
+
+	wifiBssInfoDesc = prometheus.NewDesc(
+		"freebox_wifi_bss_info",
+		"SSID and encryption of a Wi-Fi BSS",
+		[]string{
+			"bssid",
+			"access_point",
+			"ssid",
+			"encryption",
+			"hidden",
+		},
+		nil,
 	)
 
-	// vpn server connections list [unstable]
-	vpnServerConnectionsList = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "vpn_server_connections_list",
-			Help: "VPN server connections list",
+	wifiBssEnabledDesc = prometheus.NewDesc(
+		"freebox_wifi_bss_enabled",
+		"Whether a Wi-Fi BSS is enabled",
+		[]string{
+			"bssid",
 		},
+		nil,
+	)
+
+	wifiBssActiveDesc = prometheus.NewDesc(
+		"freebox_wifi_bss_active",
+		"Whether a Wi-Fi BSS is up",
+		[]string{
+			"bssid",
+		},
+		nil,
+	)
+
+	wifiBssStationsDesc = prometheus.NewDesc(
+		"freebox_wifi_bss_stations",
+		"Number of stations associated to a Wi-Fi BSS",
+		[]string{
+			"bssid",
+			"authorized", // true|false
+		},
+		nil,
+	)
+
+	wifiChannelBusyDesc = prometheus.NewDesc(
+		"freebox_wifi_channel_busy_percent",
+		"Share of the time a Wi-Fi channel is busy, as seen by an access point",
+		[]string{
+			"access_point",
+			"band",
+			"channel",
+			"type", // total|rx|tx
+		},
+		nil,
+	)
+
+	wifiChannelNoiseDesc = prometheus.NewDesc(
+		"freebox_wifi_channel_noise_dbm",
+		"Noise level on a Wi-Fi channel, as seen by an access point",
+		[]string{
+			"access_point",
+			"band",
+			"channel",
+		},
+		nil,
+	)
+
+	wifiNeighborSignalDesc = prometheus.NewDesc(
+		"freebox_wifi_neighbor_signal_dbm",
+		"Signal of a neighbouring Wi-Fi BSS heard by an access point",
+		[]string{
+			"access_point",
+			"bssid",
+			"ssid",
+			"band",
+			"channel",
+			"channel_width",
+		},
+		nil,
+	)
+
+	vpnServerConnectionBytesDesc = prometheus.NewDesc(
+		"freebox_vpn_server_connection_bytes_total",
+		"Bytes transferred by a connection to the VPN server",
+		[]string{
+			"user",
+			"vpn",
+			"src_ip",
+			"local_ip",
+			"direction", // rx|tx
+		},
+		nil,
+	)
+
+	// vpn server connections list [unstable], sent with -compat.gauges
+	vpnServerConnectionsListDesc = prometheus.NewDesc(
+		"vpn_server_connections_list",
+		"VPN server connections list",
 		[]string{
 			"user",
 			"vpn",
@@ -251,5 +891,6 @@ var (
 			"local_ip",
 			"name", // rx_bytes|tx_bytes
 		},
+		nil,
 	)
 )
@@ -1,573 +1,243 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"os"
+	"context"
+	"sort"
+	"strconv"
 	"time"
 )
 
-var (
-	apiErrors = map[string]error{
-		"invalid_token":           errors.New("The app token you are trying to use is invalid or has been revoked"),
-		"insufficient_rights":     errors.New("Your app permissions does not allow accessing this API"),
-		"denied_from_external_ip": errors.New("You are trying to get an app_token from a remote IP"),
-		"invalid_request":         errors.New("Your request is invalid"),
-		"ratelimited":             errors.New("Too many auth error have been made from your IP"),
-		"new_apps_denied":         errors.New("New application token request has been disabled"),
-		"apps_denied":             errors.New("API access from apps has been disabled"),
-		"internal_error":          errors.New("Internal error"),
-		"db_error":                errors.New("Oops, the database you are trying to access doesn't seem to exist"),
-		"nodev":                   errors.New("Invalid interface"),
-	}
-)
-
-func (r *rrd) status() error {
-	if apiErrors[r.ErrorCode] == nil {
-		return errors.New("RRD: The API returns an unknown error_code: " + r.ErrorCode)
-	}
-	return apiErrors[r.ErrorCode]
-}
-
-func (l *lan) status() error {
-	if apiErrors[l.ErrorCode] == nil {
-		return errors.New("LAN: The API returns an unknown error_code: " + l.ErrorCode)
-	}
-	return apiErrors[l.ErrorCode]
-}
-
-func setFreeboxToken(authInf *authInfo, xSessionToken *string) (string, error) {
-	token := os.Getenv("FREEBOX_TOKEN")
-
-	if token == "" {
-		var err error
-		*xSessionToken, err = getToken(authInf, xSessionToken)
-		if err != nil {
-			return "", err
-		}
-		token = *xSessionToken
-	}
-
-	if *xSessionToken == "" {
-		var err error
-		*xSessionToken, err = getSessToken(token, authInf, xSessionToken)
-		if err != nil {
-			log.Fatal(err)
-		}
-		token = *xSessionToken
-	}
-
-	return token, nil
-
-}
-
-func newPostRequest() *postRequest {
-	return &postRequest{
-		method: "POST",
-		url:    mafreebox + "api/v4/rrd/",
-		header: "X-Fbx-App-Auth",
-	}
-}
-
-func getConnectionXdsl(authInf *authInfo, pr *postRequest, xSessionToken *string) (connectionXdsl, error) {
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return connectionXdsl{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return connectionXdsl{}, err
-	}
-	if resp.StatusCode == 404 {
-		return connectionXdsl{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return connectionXdsl{}, err
-	}
-
-	connectionXdslResp := connectionXdsl{}
-	err = json.Unmarshal(body, &connectionXdslResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return connectionXdsl{}, err
-	}
-
-	return connectionXdslResp, nil
-}
-
-func getDsl(authInf *authInfo, pr *postRequest, xSessionToken *string) ([]int64, error) {
+// getRRD fetches the newest point of fields in the RRD database db,
+// with its time
+func getRRD(ctx context.Context, c *client, db string, fields []string) (map[string]int64, error) {
 	d := &database{
-		DB:        "dsl",
-		Fields:    []string{"rate_up", "rate_down", "snr_up", "snr_down"},
+		DB:        db,
+		Fields:    fields,
 		Precision: 10,
-		DateStart: int(time.Now().Unix() - 10),
+		DateStart: int(time.Now().Unix() - 60),
 	}
 
-	freeboxToken, err := setFreeboxToken(authInf, xSessionToken)
-	if err != nil {
-		return []int64{}, err
-	}
-	client := http.Client{}
-	r, err := json.Marshal(*d)
-	if err != nil {
-		return []int64{}, err
-	}
-	buf := bytes.NewReader(r)
-	req, err := http.NewRequest(pr.method, pr.url, buf)
-	if err != nil {
-		return []int64{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return []int64{}, err
-	}
-	if resp.StatusCode == 404 {
-		return []int64{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []int64{}, err
-	}
-	rrdTest := rrd{}
-	err = json.Unmarshal(body, &rrdTest)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return []int64{}, err
+	result := rrd{}
+	if err := c.post(ctx, "rrd/", d, &result); err != nil {
+		return map[string]int64{}, err
 	}
 
-	if rrdTest.ErrorCode == "auth_required" {
-		*xSessionToken, err = getSessToken(freeboxToken, authInf, xSessionToken)
-		if err != nil {
-			return []int64{}, err
+	newest := map[string]int64{}
+	for _, point := range result.Data {
+		if len(newest) == 0 || point["time"] >= newest["time"] {
+			newest = point
 		}
 	}
-
-	if rrdTest.ErrorCode != "" && rrdTest.ErrorCode != "auth_required" {
-		if rrdTest.status().Error() == "Unknown return code from the API" {
-			fmt.Println("getDsl")
-		}
-		return []int64{}, rrdTest.status()
-	}
-
-	if len(rrdTest.Result.Data) == 0 {
-		return []int64{}, nil
-	}
-
-	result := []int64{rrdTest.Result.Data[0]["rate_up"], rrdTest.Result.Data[0]["rate_down"], rrdTest.Result.Data[0]["snr_up"], rrdTest.Result.Data[0]["snr_down"]}
-	return result, nil
+	return newest, nil
 }
 
-func getTemp(authInf *authInfo, pr *postRequest, xSessionToken *string) ([]int64, error) {
-	d := &database{
-		DB:        "temp",
-		Fields:    []string{"cpum", "cpub", "sw", "hdd", "fan_speed"},
-		Precision: 10,
-		DateStart: int(time.Now().Unix() - 10),
-	}
-
-	freeboxToken, err := setFreeboxToken(authInf, xSessionToken)
-	if err != nil {
-		return []int64{}, err
-	}
-
-	client := http.Client{}
-	r, err := json.Marshal(*d)
-	if err != nil {
-		return []int64{}, err
-	}
-	buf := bytes.NewReader(r)
-	req, err := http.NewRequest(pr.method, fmt.Sprintf(pr.url), buf)
-	if err != nil {
-		return []int64{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return []int64{}, err
-	}
-	if resp.StatusCode == 404 {
-		return []int64{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []int64{}, err
-	}
-	rrdTest := rrd{}
-	err = json.Unmarshal(body, &rrdTest)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return []int64{}, err
-	}
-
-	if rrdTest.ErrorCode == "auth_required" {
-		*xSessionToken, err = getSessToken(freeboxToken, authInf, xSessionToken)
-		if err != nil {
-			return []int64{}, err
-		}
-	}
-
-	if rrdTest.ErrorCode != "" && rrdTest.ErrorCode != "auth_required" {
-		if rrdTest.status().Error() == "Unknown return code from the API" {
-			fmt.Println("getTemp")
+// getRRDHistory pages backward through the points of fields in the RRD
+// database db at precision, from before until the Freebox has no older
+// point, and returns them from the oldest to the newest
+func getRRDHistory(ctx context.Context, c *client, db string, fields []string, precision int, before int64) ([]map[string]int64, error) {
+	history := []map[string]int64{}
+	for end := before; end > 0; {
+		d := &database{
+			DB:        db,
+			Fields:    fields,
+			Precision: precision,
+			DateStart: int(end - int64(precision*rrdPageSize)),
+			DateEnd:   int(end),
 		}
-		return []int64{}, rrdTest.status()
-	}
-
-	if len(rrdTest.Result.Data) == 0 {
-		return []int64{}, nil
-	}
-
-	return []int64{rrdTest.Result.Data[0]["cpum"], rrdTest.Result.Data[0]["cpub"], rrdTest.Result.Data[0]["sw"], rrdTest.Result.Data[0]["hdd"], rrdTest.Result.Data[0]["fan_speed"]}, nil
-}
-
-func getNet(authInf *authInfo, pr *postRequest, xSessionToken *string) ([]int64, error) {
-	d := &database{
-		DB:        "net",
-		Fields:    []string{"bw_up", "bw_down", "rate_up", "rate_down", "vpn_rate_up", "vpn_rate_down"},
-		Precision: 10,
-		DateStart: int(time.Now().Unix() - 10),
-	}
 
-	freeboxToken, err := setFreeboxToken(authInf, xSessionToken)
-	if err != nil {
-		return []int64{}, err
-	}
-
-	client := http.Client{}
-	r, err := json.Marshal(*d)
-	if err != nil {
-		return []int64{}, err
-	}
-	buf := bytes.NewReader(r)
-	req, err := http.NewRequest(pr.method, pr.url, buf)
-	if err != nil {
-		return []int64{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return []int64{}, err
-	}
-	if resp.StatusCode == 404 {
-		return []int64{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []int64{}, err
-	}
-	rrdTest := rrd{}
-	err = json.Unmarshal(body, &rrdTest)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
+		result := rrd{}
+		if err := c.post(ctx, "rrd/", d, &result); err != nil {
+			return nil, err
 		}
-		return []int64{}, err
-	}
 
-	if rrdTest.ErrorCode == "auth_required" {
-		*xSessionToken, err = getSessToken(freeboxToken, authInf, xSessionToken)
-		if err != nil {
-			return []int64{}, err
+		oldest := end
+		page := []map[string]int64{}
+		for _, point := range result.Data {
+			if point["time"] > 0 && point["time"] < end {
+				page = append(page, point)
+			}
+			if point["time"] > 0 && point["time"] < oldest {
+				oldest = point["time"]
+			}
 		}
-	}
-
-	if rrdTest.ErrorCode != "" && rrdTest.ErrorCode != "auth_required" {
-		if rrdTest.status().Error() == "Unknown return code from the API" {
-			fmt.Println("getNet")
+		if len(page) == 0 {
+			break
 		}
-		return []int64{}, rrdTest.status()
-	}
 
-	if len(rrdTest.Result.Data) == 0 {
-		return []int64{}, nil
+		sort.Slice(page, func(i, j int) bool { return page[i]["time"] < page[j]["time"] })
+		history = append(page, history...)
+		end = oldest
 	}
-
-	return []int64{rrdTest.Result.Data[0]["bw_up"], rrdTest.Result.Data[0]["bw_down"], rrdTest.Result.Data[0]["rate_up"], rrdTest.Result.Data[0]["rate_down"], rrdTest.Result.Data[0]["vpn_rate_up"], rrdTest.Result.Data[0]["vpn_rate_down"]}, nil
+	return history, nil
 }
 
-func getSwitch(authInf *authInfo, pr *postRequest, xSessionToken *string) ([]int64, error) {
-	d := &database{
-		DB:        "switch",
-		Fields:    []string{"rx_1", "tx_1", "rx_2", "tx_2", "rx_3", "tx_3", "rx_4", "tx_4"},
-		Precision: 10,
-		DateStart: int(time.Now().Unix() - 10),
-	}
-
-	freeboxToken, err := setFreeboxToken(authInf, xSessionToken)
-	if err != nil {
-		return []int64{}, err
-	}
-
-	client := http.Client{}
-	r, err := json.Marshal(*d)
-	if err != nil {
-		return []int64{}, err
-	}
-	buf := bytes.NewReader(r)
-	req, err := http.NewRequest(pr.method, pr.url, buf)
-	if err != nil {
-		return []int64{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return []int64{}, err
-	}
-	if resp.StatusCode == 404 {
-		return []int64{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []int64{}, err
-	}
-	rrdTest := rrd{}
-	err = json.Unmarshal(body, &rrdTest)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return []int64{}, err
-	}
+func getConnection(ctx context.Context, c *client) (connectionStatus, error) {
+	result := connectionStatus{}
+	err := c.get(ctx, "connection/", &result)
+	return result, err
+}
 
-	if rrdTest.ErrorCode == "auth_required" {
-		*xSessionToken, err = getSessToken(freeboxToken, authInf, xSessionToken)
-		if err != nil {
-			return []int64{}, err
-		}
-	}
+func getConnectionConfig(ctx context.Context, c *client) (connectionConfig, error) {
+	result := connectionConfig{}
+	err := c.get(ctx, "connection/config/", &result)
+	return result, err
+}
 
-	if rrdTest.ErrorCode != "" && rrdTest.ErrorCode != "auth_required" {
-		if rrdTest.status().Error() == "Unknown return code from the API" {
-			fmt.Println("getSwitch")
-		}
-		return []int64{}, rrdTest.status()
-	}
+func getConnectionIPv6Config(ctx context.Context, c *client) (connectionIPv6Config, error) {
+	result := connectionIPv6Config{}
+	err := c.get(ctx, "connection/ipv6/config/", &result)
+	return result, err
+}
 
-	if len(rrdTest.Result.Data) == 0 {
-		return []int64{}, nil
-	}
+func getDdnsStatus(ctx context.Context, c *client, provider string) (ddnsStatus, error) {
+	result := ddnsStatus{}
+	err := c.get(ctx, "connection/ddns/"+provider+"/status/", &result)
+	return result, err
+}
 
-	return []int64{rrdTest.Result.Data[0]["rx_1"], rrdTest.Result.Data[0]["tx_1"], rrdTest.Result.Data[0]["rx_2"], rrdTest.Result.Data[0]["tx_2"], rrdTest.Result.Data[0]["rx_3"], rrdTest.Result.Data[0]["tx_3"], rrdTest.Result.Data[0]["rx_4"], rrdTest.Result.Data[0]["tx_4"]}, nil
+func getConnectionFtth(ctx context.Context, c *client) (connectionFtth, error) {
+	result := connectionFtth{}
+	err := c.get(ctx, "connection/ftth/", &result)
+	return result, err
 }
 
-func getLan(authInf *authInfo, pr *postRequest, xSessionToken *string) ([]lanHost, error) {
-	freeboxToken, err := setFreeboxToken(authInf, xSessionToken)
-	if err != nil {
-		return []lanHost{}, err
-	}
+func getConnectionXdsl(ctx context.Context, c *client) (connectionXdsl, error) {
+	result := connectionXdsl{}
+	err := c.get(ctx, "connection/xdsl/", &result)
+	return result, err
+}
 
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return []lanHost{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return []lanHost{}, err
-	}
-	if resp.StatusCode == 404 {
-		return []lanHost{}, err
-	}
+func getDhcpConfig(ctx context.Context, c *client) (dhcpConfig, error) {
+	result := dhcpConfig{}
+	err := c.get(ctx, "dhcp/config/", &result)
+	return result, err
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []lanHost{}, err
-	}
+func getDhcpv6Config(ctx context.Context, c *client) (dhcpv6Config, error) {
+	result := dhcpv6Config{}
+	err := c.get(ctx, "dhcpv6/config/", &result)
+	return result, err
+}
 
-	lanResp := lan{}
-	err = json.Unmarshal(body, &lanResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return []lanHost{}, err
-	}
+// getDhcpStaticLeases fetches the static leases of the DHCP server of
+// prefix, dhcp or dhcpv6
+func getDhcpStaticLeases(ctx context.Context, c *client, prefix string) ([]dhcpStaticLease, error) {
+	result := []dhcpStaticLease{}
+	err := c.get(ctx, prefix+"/static_lease/", &result)
+	return result, err
+}
 
-	if lanResp.ErrorCode == "auth_required" {
-		*xSessionToken, err = getSessToken(freeboxToken, authInf, xSessionToken)
-		if err != nil {
-			return []lanHost{}, err
-		}
-	}
+// getDhcpDynamicLeases fetches the dynamic leases of the DHCP server of
+// prefix, dhcp or dhcpv6
+func getDhcpDynamicLeases(ctx context.Context, c *client, prefix string) ([]dhcpDynamicLease, error) {
+	result := []dhcpDynamicLease{}
+	err := c.get(ctx, prefix+"/dynamic_lease/", &result)
+	return result, err
+}
 
-	if lanResp.ErrorCode != "" && lanResp.ErrorCode != "auth_required" {
-		return []lanHost{}, lanResp.status()
-	}
+func getFwRedirs(ctx context.Context, c *client) ([]fwRedir, error) {
+	result := []fwRedir{}
+	err := c.get(ctx, "fw/redir/", &result)
+	return result, err
+}
 
-	return lanResp.Result, nil
+func getFwIncoming(ctx context.Context, c *client) ([]fwIncoming, error) {
+	result := []fwIncoming{}
+	err := c.get(ctx, "fw/incoming/", &result)
+	return result, err
 }
 
-func getFreeplug(authInf *authInfo, pr *postRequest, xSessionToken *string) (freeplug, error) {
-	if _, err := setFreeboxToken(authInf, xSessionToken); err != nil {
-		return freeplug{}, err
-	}
+func getFwDmz(ctx context.Context, c *client) (fwDmz, error) {
+	result := fwDmz{}
+	err := c.get(ctx, "fw/dmz/", &result)
+	return result, err
+}
 
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return freeplug{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return freeplug{}, err
-	}
-	if resp.StatusCode == 404 {
-		return freeplug{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return freeplug{}, err
-	}
+func getUpnpRedirs(ctx context.Context, c *client) ([]upnpRedir, error) {
+	result := []upnpRedir{}
+	err := c.get(ctx, "upnpigd/redir/", &result)
+	return result, err
+}
 
-	freeplugResp := freeplug{}
-	err = json.Unmarshal(body, &freeplugResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return freeplug{}, err
-	}
+func getSwitchStatus(ctx context.Context, c *client) ([]switchPortStatus, error) {
+	result := []switchPortStatus{}
+	err := c.get(ctx, "switch/status/", &result)
+	return result, err
+}
 
-	return freeplugResp, nil
+func getSwitchPort(ctx context.Context, c *client, port int) (switchPortConfig, error) {
+	result := switchPortConfig{}
+	err := c.get(ctx, "switch/port/"+strconv.Itoa(port)+"/", &result)
+	return result, err
 }
 
-func getSystem(authInf *authInfo, pr *postRequest, xSessionToken *string) (system, error) {
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return system{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return system{}, err
-	}
-	if resp.StatusCode == 404 {
-		return system{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return system{}, err
-	}
+func getSwitchPortStats(ctx context.Context, c *client, port int) (switchPortStats, error) {
+	result := switchPortStats{}
+	err := c.get(ctx, "switch/port/"+strconv.Itoa(port)+"/stats/", &result)
+	return result, err
+}
 
-	systemResp := system{}
-	err = json.Unmarshal(body, &systemResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return system{}, err
+// getLanInterfaces lists the interfaces of the LAN browser, only pub
+// when the box cannot list them
+func getLanInterfaces(ctx context.Context, c *client) ([]lanInterface, error) {
+	result := []lanInterface{}
+	err := c.get(ctx, "lan/browser/interfaces/", &result)
+	if err == errNotFound {
+		return []lanInterface{{Name: "pub"}}, nil
 	}
-
-	return systemResp, nil
+	return result, err
 }
 
-func getWifi(authInf *authInfo, pr *postRequest, xSessionToken *string) (wifi, error) {
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return wifi{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return wifi{}, err
-	}
-	if resp.StatusCode == 404 {
-		return wifi{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return wifi{}, err
-	}
+func getLanHosts(ctx context.Context, c *client, iface string) ([]lanHost, error) {
+	result := []lanHost{}
+	err := c.get(ctx, "lan/browser/"+iface+"/", &result)
+	return result, err
+}
 
-	wifiResp := wifi{}
-	err = json.Unmarshal(body, &wifiResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return wifi{}, err
-	}
+func getFreeplug(ctx context.Context, c *client) ([]freeplugNetwork, error) {
+	result := []freeplugNetwork{}
+	err := c.get(ctx, "freeplug/", &result)
+	return result, err
+}
 
-	return wifiResp, nil
+func getSystem(ctx context.Context, c *client) (system, error) {
+	result := system{}
+	err := c.get(ctx, "system/", &result)
+	return result, err
 }
 
-func getWifiStations(authInf *authInfo, pr *postRequest, xSessionToken *string) (wifiStations, error) {
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return wifiStations{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return wifiStations{}, err
-	}
-	if resp.StatusCode == 404 {
-		return wifiStations{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return wifiStations{}, err
-	}
+func getWifi(ctx context.Context, c *client) ([]wifiAccessPoint, error) {
+	result := []wifiAccessPoint{}
+	err := c.get(ctx, "wifi/ap/", &result)
+	return result, err
+}
 
-	wifiStationResp := wifiStations{}
-	err = json.Unmarshal(body, &wifiStationResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return wifiStations{}, err
-	}
+func getWifiChannelUsage(ctx context.Context, c *client, accessPoint int) ([]wifiChannelUsage, error) {
+	result := []wifiChannelUsage{}
+	err := c.get(ctx, "wifi/ap/"+strconv.Itoa(accessPoint)+"/channel_usage/", &result)
+	return result, err
+}
 
-	return wifiStationResp, nil
+func getWifiNeighbors(ctx context.Context, c *client, accessPoint int) ([]wifiNeighbor, error) {
+	result := []wifiNeighbor{}
+	err := c.get(ctx, "wifi/ap/"+strconv.Itoa(accessPoint)+"/neighbors/", &result)
+	return result, err
 }
 
-func getVpnServer(authInf *authInfo, pr *postRequest, xSessionToken *string) (vpnServer, error) {
-	client := http.Client{}
-	req, err := http.NewRequest(pr.method, pr.url, nil)
-	if err != nil {
-		return vpnServer{}, err
-	}
-	req.Header.Add(pr.header, *xSessionToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return vpnServer{}, err
-	}
-	if resp.StatusCode == 404 {
-		return vpnServer{}, errors.New(resp.Status)
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return vpnServer{}, err
-	}
+func getWifiBss(ctx context.Context, c *client) ([]wifiBss, error) {
+	result := []wifiBss{}
+	err := c.get(ctx, "wifi/bss/", &result)
+	return result, err
+}
 
-	vpnServerResp := vpnServer{}
-	err = json.Unmarshal(body, &vpnServerResp)
-	if err != nil {
-		if debug {
-			log.Println(string(body))
-		}
-		return vpnServer{}, err
-	}
+func getWifiStations(ctx context.Context, c *client, accessPoint int) ([]wifiStation, error) {
+	result := []wifiStation{}
+	err := c.get(ctx, "wifi/ap/"+strconv.Itoa(accessPoint)+"/stations", &result)
+	return result, err
+}
 
-	return vpnServerResp, nil
+func getVpnServer(ctx context.Context, c *client) ([]vpnConnection, error) {
+	result := []vpnConnection{}
+	err := c.get(ctx, "vpn/connection/", &result)
+	return result, err
 }
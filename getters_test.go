@@ -1,155 +1,59 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
-	"fmt"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"reflect"
-	"strings"
 	"testing"
 )
 
-func TestSetFreeboxToken(t *testing.T) {
-
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.RequestURI {
-		case "/login":
-			myChall := &challenge{
-				Success: true,
-			}
-			myChall.Result.Challenge = "foobar"
-			result, _ := json.Marshal(myChall)
-			fmt.Fprintln(w, string(result))
-		case "/session":
-			myToken := sessionToken{
-				Success: true,
-			}
-			myToken.Result.SessionToken = "foobar"
-			result, _ := json.Marshal(myToken)
-			fmt.Fprintln(w, string(result))
-		case "/granted/":
-			myTrack := track{
-				Success: true,
-			}
-			myTrack.Result.TrackID = 101
-			result, _ := json.Marshal(myTrack)
-			fmt.Fprintln(w, string(result))
-		case "/granted/101":
-			myGrant := grant{
-				Success: true,
-			}
-			myGrant.Result.Status = "granted"
-			result, _ := json.Marshal(myGrant)
-			fmt.Fprintln(w, string(result))
-		default:
-			fmt.Fprintln(w, http.StatusNotFound)
+// newRRDServer answers RRD requests with data, the "error" mode
+// with errorCode and the "null" mode without any data point
+func newRRDServer(mode *string, data map[string]int64, errorCode string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.RequestURI != "/api/v4/rrd/" || r.Method != "POST" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch *mode {
+		case "good":
+			writeResult(w, rrd{Data: []map[string]int64{data}})
+		case "error":
+			writeError(w, errorCode)
+		case "null":
+			writeResult(w, rrd{})
 		}
 	}))
-	defer ts.Close()
-
-	ai := &authInfo{}
-	ai.myStore.location = "/tmp/token"
-	ai.myAPI.login = ts.URL + "/login"
-	ai.myAPI.loginSession = ts.URL + "/session"
-	ai.myAPI.authz = ts.URL + "/granted/"
-	ai.myReader = bufio.NewReader(strings.NewReader("\n"))
-
-	var mySessionToken string
-
-	token, err := setFreeboxToken(ai, &mySessionToken)
-	if err != nil {
-		t.Error("Expected no err, but got", err)
-	}
-	defer os.Remove(ai.myStore.location)
-
-	if token != "foobar" {
-		t.Error("Expected foobar, but got", token)
-	}
-
-	os.Setenv("FREEBOX_TOKEN", "barfoo")
-	defer os.Unsetenv("FREEBOX_TOKEN")
-
-	token, err = setFreeboxToken(ai, &mySessionToken)
-	if err != nil {
-		t.Error("Expected no err, but got", err)
-	}
-
-	if token != "barfoo" {
-		t.Error("Expected barfoo, but got", token)
-	}
 }
 
 func TestGetDsl(t *testing.T) {
 	os.Setenv("FREEBOX_TOKEN", "IOI")
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.RequestURI {
-		case "/good":
-			myRRD := rrd{
-				Success: true,
-			}
-			myRRD.Result.Data = []map[string]int64{
-				{
-					"rate_up":   12,
-					"rate_down": 34,
-					"snr_up":    56,
-					"snr_down":  78,
-				},
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/error":
-			myRRD := rrd{
-				Success:   true,
-				ErrorCode: "insufficient_rights",
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/null":
-			myRRD := rrd{
-				Success: true,
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		}
-	}))
+	mode := "good"
+	ts := newRRDServer(&mode, map[string]int64{
+		"rate_up":   12,
+		"rate_down": 34,
+		"snr_up":    56,
+		"snr_down":  78,
+	}, "insufficient_rights")
 	defer ts.Close()
 
-	goodPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/good",
-	}
-
-	errorPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/error",
-	}
-
-	nullPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/null",
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
+	fields := []string{"rate_up", "rate_down", "snr_up", "snr_down"}
 
-	getDslResult, err := getDsl(ai, goodPR, &mySessionToken)
+	getDslResult, err := getRRD(context.Background(), c, "dsl", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	if getDslResult[0] != 12 || getDslResult[1] != 34 || getDslResult[2] != 56 || getDslResult[3] != 78 {
-		t.Errorf("Expected 12 34 56 78, but got %v %v %v %v\n", getDslResult[0], getDslResult[1], getDslResult[2], getDslResult[3])
+	if getDslResult["rate_up"] != 12 || getDslResult["rate_down"] != 34 || getDslResult["snr_up"] != 56 || getDslResult["snr_down"] != 78 {
+		t.Errorf("Expected 12 34 56 78, but got %v %v %v %v\n", getDslResult["rate_up"], getDslResult["rate_down"], getDslResult["snr_up"], getDslResult["snr_down"])
 	}
 
-	getDslResult, err = getDsl(ai, errorPR, &mySessionToken)
+	mode = "error"
+	getDslResult, err = getRRD(context.Background(), c, "dsl", fields)
 	if err.Error() != "Your app permissions does not allow accessing this API" {
 		t.Error("Expected Your app permissions does not allow accessing this API, but go", err)
 	}
@@ -158,7 +62,8 @@ func TestGetDsl(t *testing.T) {
 		t.Error("Expected 0, but got", len(getDslResult))
 	}
 
-	getDslResult, err = getDsl(ai, nullPR, &mySessionToken)
+	mode = "null"
+	getDslResult, err = getRRD(context.Background(), c, "dsl", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -173,71 +78,30 @@ func TestGetTemp(t *testing.T) {
 	os.Setenv("FREEBOX_TOKEN", "IOI")
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.RequestURI {
-		case "/good":
-			myRRD := rrd{
-				Success: true,
-			}
-			myRRD.Result.Data = []map[string]int64{
-				{
-					"cpum":      01,
-					"cpub":      02,
-					"sw":        03,
-					"hdd":       04,
-					"fan_speed": 05,
-				},
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/error":
-			myRRD := rrd{
-				Success:   true,
-				ErrorCode: "denied_from_external_ip",
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/null":
-			myRRD := rrd{
-				Success: true,
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		}
-	}))
+	mode := "good"
+	ts := newRRDServer(&mode, map[string]int64{
+		"cpum":      01,
+		"cpub":      02,
+		"sw":        03,
+		"hdd":       04,
+		"fan_speed": 05,
+	}, "denied_from_external_ip")
 	defer ts.Close()
 
-	goodPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/good",
-	}
-
-	errorPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/error",
-	}
-
-	nullPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/null",
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
+	fields := []string{"cpum", "cpub", "sw", "hdd", "fan_speed"}
 
-	getTempResult, err := getTemp(ai, goodPR, &mySessionToken)
+	getTempResult, err := getRRD(context.Background(), c, "temp", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	if getTempResult[0] != 01 || getTempResult[1] != 02 || getTempResult[2] != 03 || getTempResult[3] != 04 || getTempResult[4] != 05 {
-		t.Errorf("Expected 01 02 03 04 05, but got %v %v %v %v %v\n", getTempResult[0], getTempResult[1], getTempResult[2], getTempResult[3], getTempResult[4])
+	if getTempResult["cpum"] != 01 || getTempResult["cpub"] != 02 || getTempResult["sw"] != 03 || getTempResult["hdd"] != 04 || getTempResult["fan_speed"] != 05 {
+		t.Errorf("Expected 01 02 03 04 05, but got %v %v %v %v %v\n", getTempResult["cpum"], getTempResult["cpub"], getTempResult["sw"], getTempResult["hdd"], getTempResult["fan_speed"])
 	}
 
-	getTempResult, err = getTemp(ai, errorPR, &mySessionToken)
+	mode = "error"
+	getTempResult, err = getRRD(context.Background(), c, "temp", fields)
 	if err.Error() != "You are trying to get an app_token from a remote IP" {
 		t.Error("Expected You are trying to get an app_token from a remote IP, but go", err)
 	}
@@ -246,7 +110,8 @@ func TestGetTemp(t *testing.T) {
 		t.Error("Expected 0, but got", len(getTempResult))
 	}
 
-	getTempResult, err = getTemp(ai, nullPR, &mySessionToken)
+	mode = "null"
+	getTempResult, err = getRRD(context.Background(), c, "temp", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -261,72 +126,31 @@ func TestGetNet(t *testing.T) {
 	os.Setenv("FREEBOX_TOKEN", "IOI")
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.RequestURI {
-		case "/good":
-			myRRD := rrd{
-				Success: true,
-			}
-			myRRD.Result.Data = []map[string]int64{
-				{
-					"bw_up":         12500000000,
-					"bw_down":       12500000000,
-					"rate_up":       12500000000,
-					"rate_down":     12500000000,
-					"vpn_rate_up":   12500000000,
-					"vpn_rate_down": 12500000000,
-				},
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/error":
-			myRRD := rrd{
-				Success:   true,
-				ErrorCode: "new_apps_denied",
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/null":
-			myRRD := rrd{
-				Success: true,
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		}
-	}))
+	mode := "good"
+	ts := newRRDServer(&mode, map[string]int64{
+		"bw_up":         12500000000,
+		"bw_down":       12500000000,
+		"rate_up":       12500000000,
+		"rate_down":     12500000000,
+		"vpn_rate_up":   12500000000,
+		"vpn_rate_down": 12500000000,
+	}, "new_apps_denied")
 	defer ts.Close()
 
-	goodPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/good",
-	}
-
-	errorPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/error",
-	}
-
-	nullPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/null",
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
+	fields := []string{"bw_up", "bw_down", "rate_up", "rate_down", "vpn_rate_up", "vpn_rate_down"}
 
-	getNetResult, err := getNet(ai, goodPR, &mySessionToken)
+	getNetResult, err := getRRD(context.Background(), c, "net", fields)
 	if err != nil {
 		t.Error("Expected no err, but go", err)
 	}
 
-	if getNetResult[0] != 12500000000 || getNetResult[1] != 12500000000 || getNetResult[2] != 12500000000 || getNetResult[3] != 12500000000 || getNetResult[4] != 12500000000 || getNetResult[5] != 12500000000 {
-		t.Errorf("Expected 01 02 03 04 05 06, but got %v %v %v %v %v %v\n", getNetResult[0], getNetResult[1], getNetResult[2], getNetResult[3], getNetResult[4], getNetResult[5])
+	if getNetResult["bw_up"] != 12500000000 || getNetResult["bw_down"] != 12500000000 || getNetResult["rate_up"] != 12500000000 || getNetResult["rate_down"] != 12500000000 || getNetResult["vpn_rate_up"] != 12500000000 || getNetResult["vpn_rate_down"] != 12500000000 {
+		t.Errorf("Expected 01 02 03 04 05 06, but got %v %v %v %v %v %v\n", getNetResult["bw_up"], getNetResult["bw_down"], getNetResult["rate_up"], getNetResult["rate_down"], getNetResult["vpn_rate_up"], getNetResult["vpn_rate_down"])
 	}
 
-	getNetResult, err = getNet(ai, errorPR, &mySessionToken)
+	mode = "error"
+	getNetResult, err = getRRD(context.Background(), c, "net", fields)
 	if err.Error() != "New application token request has been disabled" {
 		t.Error("Expected New application token request has been disabled, but got", err)
 	}
@@ -335,7 +159,8 @@ func TestGetNet(t *testing.T) {
 		t.Error("Expected 0, but got", len(getNetResult))
 	}
 
-	getNetResult, err = getNet(ai, nullPR, &mySessionToken)
+	mode = "null"
+	getNetResult, err = getRRD(context.Background(), c, "net", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -350,74 +175,33 @@ func TestGetSwitch(t *testing.T) {
 	os.Setenv("FREEBOX_TOKEN", "IOI")
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.RequestURI {
-		case "/good":
-			myRRD := rrd{
-				Success: true,
-			}
-			myRRD.Result.Data = []map[string]int64{
-				{
-					"rx_1": 01,
-					"tx_1": 11,
-					"rx_2": 02,
-					"tx_2": 12,
-					"rx_3": 03,
-					"tx_3": 13,
-					"rx_4": 04,
-					"tx_4": 14,
-				},
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/error":
-			myRRD := rrd{
-				Success:   true,
-				ErrorCode: "apps_denied",
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		case "/null":
-			myRRD := rrd{
-				Success: true,
-			}
-			result, _ := json.Marshal(myRRD)
-			fmt.Fprintln(w, string(result))
-		}
-	}))
+	mode := "good"
+	ts := newRRDServer(&mode, map[string]int64{
+		"rx_1": 01,
+		"tx_1": 11,
+		"rx_2": 02,
+		"tx_2": 12,
+		"rx_3": 03,
+		"tx_3": 13,
+		"rx_4": 04,
+		"tx_4": 14,
+	}, "apps_denied")
 	defer ts.Close()
 
-	goodPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/good",
-	}
-
-	errorPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/error",
-	}
-
-	nullPR := &postRequest{
-		method: "POST",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/null",
-	}
+	c := newTestClient(ts.URL)
+	fields := []string{"rx_1", "tx_1", "rx_2", "tx_2", "rx_3", "tx_3", "rx_4", "tx_4"}
 
-	ai := &authInfo{}
-	mySessionToken := "foobar"
-
-	getSwitchResult, err := getSwitch(ai, goodPR, &mySessionToken)
+	getSwitchResult, err := getRRD(context.Background(), c, "switch", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	if getSwitchResult[0] != 01 || getSwitchResult[1] != 11 || getSwitchResult[2] != 02 || getSwitchResult[3] != 12 || getSwitchResult[4] != 03 || getSwitchResult[5] != 13 || getSwitchResult[6] != 04 || getSwitchResult[7] != 14 {
-		t.Errorf("Expected 01 11 02 12 03 13 04 14, but got %v %v %v %v %v %v %v %v\n", getSwitchResult[0], getSwitchResult[1], getSwitchResult[2], getSwitchResult[3], getSwitchResult[4], getSwitchResult[5], getSwitchResult[6], getSwitchResult[7])
+	if getSwitchResult["rx_1"] != 01 || getSwitchResult["tx_1"] != 11 || getSwitchResult["rx_2"] != 02 || getSwitchResult["tx_2"] != 12 || getSwitchResult["rx_3"] != 03 || getSwitchResult["tx_3"] != 13 || getSwitchResult["rx_4"] != 04 || getSwitchResult["tx_4"] != 14 {
+		t.Errorf("Expected 01 11 02 12 03 13 04 14, but got %v %v %v %v %v %v %v %v\n", getSwitchResult["rx_1"], getSwitchResult["tx_1"], getSwitchResult["rx_2"], getSwitchResult["tx_2"], getSwitchResult["rx_3"], getSwitchResult["tx_3"], getSwitchResult["rx_4"], getSwitchResult["tx_4"])
 	}
 
-	getSwitchResult, err = getSwitch(ai, errorPR, &mySessionToken)
+	mode = "error"
+	getSwitchResult, err = getRRD(context.Background(), c, "switch", fields)
 	if err.Error() != "API access from apps has been disabled" {
 		t.Error("Expected API access from apps has been disabled, but got", err)
 	}
@@ -426,7 +210,8 @@ func TestGetSwitch(t *testing.T) {
 		t.Error("Expected 0, but got", len(getSwitchResult))
 	}
 
-	getSwitchResult, err = getSwitch(ai, nullPR, &mySessionToken)
+	mode = "null"
+	getSwitchResult, err = getRRD(context.Background(), c, "switch", fields)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
@@ -437,60 +222,47 @@ func TestGetSwitch(t *testing.T) {
 
 }
 
-func TestGetLan(t *testing.T) {
+func TestBrowseLan(t *testing.T) {
 	os.Setenv("FREEBOX_TOKEN", "IOI")
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
+	mode := "good"
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.RequestURI {
-		case "/good":
-			myLan := lan{
-				Success: true,
-			}
-			myLan.Result = []lanHost{
+		switch {
+		case mode == "error":
+			writeError(w, "ratelimited")
+		case r.RequestURI == "/api/v4/lan/browser/interfaces/":
+			writeResult(w, []lanInterface{{Name: "pub", HostCount: 1}, {Name: "wifiguest", HostCount: 1}})
+		case r.RequestURI == "/api/v4/lan/browser/pub/":
+			writeResult(w, []lanHost{
 				{
 					Reachable:   true,
 					PrimaryName: "Reachable host",
 				},
+			})
+		case r.RequestURI == "/api/v4/lan/browser/wifiguest/":
+			writeResult(w, []lanHost{
 				{
 					Reachable:   false,
 					PrimaryName: "Unreachable host",
 				},
-			}
-			result, _ := json.Marshal(myLan)
-			fmt.Fprintln(w, string(result))
-		case "/error":
-			myLan := lan{
-				Success:   true,
-				ErrorCode: "ratelimited",
-			}
-			result, _ := json.Marshal(myLan)
-			fmt.Fprintln(w, string(result))
+			})
 		}
 	}))
 	defer ts.Close()
 
-	goodPR := &postRequest{
-		method: "GET",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/good",
-	}
-
-	errorPR := &postRequest{
-		method: "GET",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL + "/error",
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
 
-	lanAvailable, err := getLan(ai, goodPR, &mySessionToken)
+	ifaces, hosts, err := browseLan(context.Background(), c)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	for _, v := range lanAvailable {
+	if len(ifaces) != 2 || len(hosts["pub"]) != 1 || len(hosts["wifiguest"]) != 1 {
+		t.Error("Expected a host on pub and a host on wifiguest, but got", hosts)
+	}
+
+	for _, v := range append(hosts["pub"], hosts["wifiguest"]...) {
 		if v.Reachable && v.PrimaryName != "Reachable host" {
 			t.Errorf("Expected Reachable: true, Host: Reachable host, but go Reachable: %v, Host: %v", v.Reachable, v.PrimaryName)
 		}
@@ -500,7 +272,8 @@ func TestGetLan(t *testing.T) {
 		}
 	}
 
-	lanAvailable, err = getLan(ai, errorPR, &mySessionToken)
+	mode = "error"
+	_, _, err = browseLan(context.Background(), c)
 	if err.Error() != "Too many auth error have been made from your IP" {
 		t.Error("Expected Too many auth error have been made from your IP, but got", err)
 	}
@@ -512,61 +285,41 @@ func TestGetSystem(t *testing.T) {
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		mySys := system{
-			Success: true,
-		}
-		mySys.Result.FanRPM = 666
-		mySys.Result.TempCpub = 81
-		mySys.Result.TempCpum = 89
-		mySys.Result.TempHDD = 30
-		mySys.Result.TempSW = 54
-
-		/*
-			mySys.Result {
-				FanRPM:   666,
-				TempCpub: 81,
-				TempCpum: 89,
-				TempHDD:  30,
-				TempSW:   54,
-			}
-		*/
-		result, _ := json.Marshal(mySys)
-		fmt.Fprintln(w, string(result))
+		writeResult(w, system{
+			FanRPM:   666,
+			TempCpub: 81,
+			TempCpum: 89,
+			TempHDD:  30,
+			TempSW:   54,
+		})
 	}))
 	defer ts.Close()
 
-	pr := &postRequest{
-		method: "GET",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL,
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
 
-	systemStats, err := getSystem(ai, pr, &mySessionToken)
+	systemStats, err := getSystem(context.Background(), c)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	if systemStats.Result.FanRPM != 666 {
-		t.Error("Expected 666, but got", systemStats.Result.FanRPM)
+	if systemStats.FanRPM != 666 {
+		t.Error("Expected 666, but got", systemStats.FanRPM)
 	}
 
-	if systemStats.Result.TempCpub != 81 {
-		t.Error("Expected 81, but got", systemStats.Result.TempCpub)
+	if systemStats.TempCpub != 81 {
+		t.Error("Expected 81, but got", systemStats.TempCpub)
 	}
 
-	if systemStats.Result.TempCpum != 89 {
-		t.Error("Expected 89, but got", systemStats.Result.TempCpum)
+	if systemStats.TempCpum != 89 {
+		t.Error("Expected 89, but got", systemStats.TempCpum)
 	}
 
-	if systemStats.Result.TempHDD != 30 {
-		t.Error("Expected 30, but got", systemStats.Result.TempHDD)
+	if systemStats.TempHDD != 30 {
+		t.Error("Expected 30, but got", systemStats.TempHDD)
 	}
 
-	if systemStats.Result.TempSW != 54 {
-		t.Error("Expected 54, but got", systemStats.Result.TempSW)
+	if systemStats.TempSW != 54 {
+		t.Error("Expected 54, but got", systemStats.TempSW)
 	}
 
 }
@@ -576,40 +329,27 @@ func TestGetWifi(t *testing.T) {
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		myWifi := wifi{
-			Success: true,
-		}
 		myAP := wifiAccessPoint{
 			Name: "AP1",
 			ID:   0,
 		}
-		myWifi.Result = []wifiAccessPoint{myAP}
-
-		result, _ := json.Marshal(myWifi)
-		fmt.Fprintln(w, string(result))
+		writeResult(w, []wifiAccessPoint{myAP})
 	}))
 	defer ts.Close()
 
-	pr := &postRequest{
-		method: "GET",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL,
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
 
-	wifiStats, err := getWifi(ai, pr, &mySessionToken)
+	wifiStats, err := getWifi(context.Background(), c)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	if wifiStats.Result[0].Name != "AP1" {
-		t.Error("Expected AP1, but got", wifiStats.Result[0].Name)
+	if wifiStats[0].Name != "AP1" {
+		t.Error("Expected AP1, but got", wifiStats[0].Name)
 	}
 
-	if wifiStats.Result[0].ID != 0 {
-		t.Error("Expected 0, but got", wifiStats.Result[0].ID)
+	if wifiStats[0].ID != 0 {
+		t.Error("Expected 0, but got", wifiStats[0].ID)
 	}
 
 }
@@ -619,8 +359,9 @@ func TestGetWifiStations(t *testing.T) {
 	defer os.Unsetenv("FREEBOX_TOKEN")
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		myWifiStations := wifiStations{
-			Success: true,
+		if r.RequestURI != "/api/v2/wifi/ap/1/stations" {
+			w.WriteHeader(http.StatusNotFound)
+			return
 		}
 
 		myStation := wifiStation{
@@ -635,93 +376,87 @@ func TestGetWifiStations(t *testing.T) {
 			RXRate:             5,
 			Signal:             -20,
 		}
-		myWifiStations.Result = []wifiStation{myStation}
-
-		result, _ := json.Marshal(myWifiStations)
-		fmt.Fprintln(w, string(result))
+		writeResult(w, []wifiStation{myStation})
 	}))
 	defer ts.Close()
 
-	pr := &postRequest{
-		method: "GET",
-		header: "X-Fbx-App-Auth",
-		url:    ts.URL,
-	}
-
-	ai := &authInfo{}
-	mySessionToken := "foobar"
+	c := newTestClient(ts.URL)
 
-	wifiStationsStats, err := getWifiStations(ai, pr, &mySessionToken)
+	wifiStationsStats, err := getWifiStations(context.Background(), c, 1)
 	if err != nil {
 		t.Error("Expected no err, but got", err)
 	}
 
-	if wifiStationsStats.Result[0].Hostname != "station_host" {
-		t.Error("Expected station_host, but got", wifiStationsStats.Result[0].Hostname)
+	if wifiStationsStats[0].Hostname != "station_host" {
+		t.Error("Expected station_host, but got", wifiStationsStats[0].Hostname)
 	}
 
-	if wifiStationsStats.Result[0].MAC != "AA:BB:CC:DD:EE:FF" {
-		t.Error("Expected AA:BB:CC:DD:EE:FF, but got", wifiStationsStats.Result[0].MAC)
+	if wifiStationsStats[0].MAC != "AA:BB:CC:DD:EE:FF" {
+		t.Error("Expected AA:BB:CC:DD:EE:FF, but got", wifiStationsStats[0].MAC)
 	}
 
-	if wifiStationsStats.Result[0].State != "authorized" {
-		t.Error("Expected authorized, but got", wifiStationsStats.Result[0].State)
+	if wifiStationsStats[0].State != "authorized" {
+		t.Error("Expected authorized, but got", wifiStationsStats[0].State)
 	}
 
-	if wifiStationsStats.Result[0].Inactive != 60 {
-		t.Error("Expected 60, but got", wifiStationsStats.Result[0].Inactive)
+	if wifiStationsStats[0].Inactive != 60 {
+		t.Error("Expected 60, but got", wifiStationsStats[0].Inactive)
 	}
 
-	if wifiStationsStats.Result[0].RXBytes != 500 {
-		t.Error("Expected 500, but got", wifiStationsStats.Result[0].RXBytes)
+	if wifiStationsStats[0].RXBytes != 500 {
+		t.Error("Expected 500, but got", wifiStationsStats[0].RXBytes)
 	}
 
-	if wifiStationsStats.Result[0].TXBytes != 10000 {
-		t.Error("Expected 10000, but got", wifiStationsStats.Result[0].TXBytes)
+	if wifiStationsStats[0].TXBytes != 2280000000 {
+		t.Error("Expected 2280000000, but got", wifiStationsStats[0].TXBytes)
 	}
 
-	if wifiStationsStats.Result[0].ConnectionDuration != 600 {
-		t.Error("Expected 600, but got", wifiStationsStats.Result[0].ConnectionDuration)
+	if wifiStationsStats[0].ConnectionDuration != 600 {
+		t.Error("Expected 600, but got", wifiStationsStats[0].ConnectionDuration)
 	}
 
-	if wifiStationsStats.Result[0].TXRate != 20 {
-		t.Error("Expected 20, but got", wifiStationsStats.Result[0].TXRate)
+	if wifiStationsStats[0].TXRate != 4260000000 {
+		t.Error("Expected 4260000000, but got", wifiStationsStats[0].TXRate)
 	}
 
-	if wifiStationsStats.Result[0].RXRate != 5 {
-		t.Error("Expected 5, but got", wifiStationsStats.Result[0].RXRate)
+	if wifiStationsStats[0].RXRate != 5 {
+		t.Error("Expected 5, but got", wifiStationsStats[0].RXRate)
 	}
 
-	if wifiStationsStats.Result[0].Signal != -20 {
-		t.Error("Expected -20, but got", wifiStationsStats.Result[0].Signal)
+	if wifiStationsStats[0].Signal != -20 {
+		t.Error("Expected -20, but got", wifiStationsStats[0].Signal)
 	}
 
 }
 
-func Test_getNet(t *testing.T) {
-	type args struct {
-		authInf       *authInfo
-		pr            *postRequest
-		xSessionToken *string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    []int
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := getNet(tt.args.authInf, tt.args.pr, tt.args.xSessionToken)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("getNet() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getNet() = %v, want %v", got, tt.want)
-			}
+func TestGetVpnServer(t *testing.T) {
+	os.Setenv("FREEBOX_TOKEN", "IOI")
+	defer os.Unsetenv("FREEBOX_TOKEN")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, []vpnConnection{
+			{
+				User:    "user",
+				RxBytes: 42,
+				TxBytes: 24,
+			},
 		})
+	}))
+	defer ts.Close()
+
+	c := newTestClient(ts.URL)
+
+	connections, err := getVpnServer(context.Background(), c)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if connections[0].User != "user" {
+		t.Error("Expected user, but got", connections[0].User)
 	}
+
+	if connections[0].RxBytes != 42 || connections[0].TxBytes != 24 {
+		t.Errorf("Expected 42 24, but got %v %v", connections[0].RxBytes, connections[0].TxBytes)
+	}
+
 }
@@ -4,6 +4,9 @@ go 1.14
 
 require (
 	github.com/golang/protobuf v1.2.1-0.20190109072247-347cf4a86c1c // indirect
+	github.com/hashicorp/mdns v1.0.4
 	github.com/iancoleman/strcase v0.0.0-20191112232945-16388991a334
 	github.com/prometheus/client_golang v0.9.2
+	github.com/prometheus/client_model v0.0.0-20180712105110-5c3871d89910
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// freeboxRootCAs are the Freebox ECC and RSA root certificates
+// which sign the certificate of the api_domain of every box
+const freeboxRootCAs = `-----BEGIN CERTIFICATE-----
+MIICWTCCAd+gAwIBAgIJAMaRcLnIgyukMAoGCCqGSM49BAMCMGExCzAJBgNVBAYT
+AkZSMQ8wDQYDVQQIDAZGcmFuY2UxDjAMBgNVBAcMBVBhcmlzMRMwEQYDVQQKDApG
+cmVlYm94IFNBMRwwGgYDVQQDDBNGcmVlYm94IEVDQyBSb290IENBMB4XDTE1MDkw
+MTE4MDIwN1oXDTM1MDgyNzE4MDIwN1owYTELMAkGA1UEBhMCRlIxDzANBgNVBAgM
+BkZyYW5jZTEOMAwGA1UEBwwFUGFyaXMxEzARBgNVBAoMCkZyZWVib3ggU0ExHDAa
+BgNVBAMME0ZyZWVib3ggRUNDIFJvb3QgQ0EwdjAQBgcqhkjOPQIBBgUrgQQAIgNi
+AASCjD6ZKn5ko6cU5Vxh8GA1KqRi6p2GQzndxHtuUmwY8RvBbhZ0GIL7bQ4f08ae
+JOv0ycWjEW0fyOnAw6AYdsN6y1eNvH2DVfoXQyGoCSvXQNAUxla+sJuLGICRYiZz
+mnijYzBhMB0GA1UdDgQWBBTIB3c2GlbV6EIh2ErEMJvFxMz/QTAfBgNVHSMEGDAW
+gBTIB3c2GlbV6EIh2ErEMJvFxMz/QTAPBgNVHRMBAf8EBTADAQH/MA4GA1UdDwEB
+/wQEAwIBhjAKBggqhkjOPQQDAgNoADBlAjA8tzEMRVX8vrFuOGDhvZr7OSJjbBr8
+gl2I70LeVNGEXZsAThUkqj5Rg9bV8xw3aSMCMQCDjB5CgsLH8EdZmiksdBRRKM2r
+vxo6c0dSSNrr7dDN+m2/dRvgoIpGL2GauOGqDFY=
+-----END CERTIFICATE-----
+-----BEGIN CERTIFICATE-----
+MIIFmjCCA4KgAwIBAgIJAKLyz15lYOrYMA0GCSqGSIb3DQEBCwUAMFoxCzAJBgNV
+BAYTAkZSMQ8wDQYDVQQIDAZGcmFuY2UxDjAMBgNVBAcMBVBhcmlzMRAwDgYDVQQK
+DAdGcmVlYm94MRgwFgYDVQQDDA9GcmVlYm94IFJvb3QgQ0EwHhcNMTUwNzMwMTUw
+OTIwWhcNMzUwNzI1MTUwOTIwWjBaMQswCQYDVQQGEwJGUjEPMA0GA1UECAwGRnJh
+bmNlMQ4wDAYDVQQHDAVQYXJpczEQMA4GA1UECgwHRnJlZWJveDEYMBYGA1UEAwwP
+RnJlZWJveCBSb290IENBMIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEA
+xqYIvq8538SH6BJ99jDlOPoyDBrlwKEp879oYplicTC2/p0X66R/ft0en1uSQadC
+sL/JTyfgyJAgI1Dq2Y5EYVT/7G6GBtVH6Bxa713mM+I/v0JlTGFalgMqamMuIRDQ
+tdyvqEIs8DcfGB/1l2A8UhKOFbHQsMcigxOe9ZodMhtVNn0mUyG+9Zgu1e/YMhsS
+iG4Kqap6TGtk80yruS1mMWVSgLOq9F5BGD4rlNlWLo0C3R10mFCpqvsFU+g4kYoA
+dTxaIpi1pgng3CGLE0FXgwstJz8RBaZmaXDD6lGmB+5UzVTd7Iq36zaGmUs1hd8Q
+sTr5HiBNhl7B6bx6Bk/XP2PWqfNhqDt4QtS6cGNf7jV6xBvRBSB/Iq6KLcJx1h2V
+A/j7GGNZj1w7MSoVzQFzj+RTA8sPLmKpnsxDnN9b35yN2kcpEGtaxxUdeLwJNr+F
+tNCVcJfxd4i4CbR2RnU8hKzkIeFBmxQOe4Rjm7e5RkHMmeCzb/rELGi0pGWT53dD
+UrOkrY9BQUBlwxmIKMBEnkjopm55v2SRrSB5v2gW3d3cU1/kOD0Hpw4BwIGiUNdH
+7Y+j7ZLqcBi6DuWdXm8YCUFZcOeNEyeZ1HtJM6ccXL8vnb/uZyERBVEj/AYt0dDs
+3wYN5Lu0cNcunA4sVFhxOuwY9u9cWE76MdEpzUzPGn8CAwEAAaNjMGEwHQYDVR0O
+BBYEFCy4yRD81ub9vbd6nM7mgv6AFkemMB8GA1UdIwQYMBaAFCy4yRD81ub9vbd6
+nM7mgv6AFkemMA8GA1UdEwEB/wQFMAMBAf8wDgYDVR0PAQH/BAQDAgGGMA0GCSqG
+SIb3DQEBCwUAA4ICAQBUkzp3iIyOQ+XTZhGKJy72R0tVO/RXjPvGpkHAY+MW8H6U
+QU0gWzFNY1+SxZxZOUUxcZNQKKpp1qQ3XQNG+b/Kf5gpkc7E9R1r0jJ6YH/eYUVp
+uYoSdSGTzOuxaH5Yb0Q3dLP4XfgURsfGlT6J2ibz1Z7R6APVKANfEcgbkGWUKBzp
+hgJCK71PD1CPmT2SbyJu8c1aljCXwGIebXZRDlRpGgJO0ZC7e92npoldBkpj1D/W
+LEnG5s+ABoPnLkf1ArBLtIdvyHkm5Ou4BY6I2I34QGKx1Ot3h1OlVd6UP6rpfRoM
+KoC0T/Q/Fw1wHzDO+QMcWPZwqhnm7xkZvqxtIpI1dBbTvoLDh2+Uuz3PEdPyzzym
+UO6QKspyHpnpjUdHHmGMmvMUi4GTgGAw5TB2A+Yvy4TUN7HQJRX5Kp28MBWOKIsO
+nwafPbcU4ypuDu7sp2Tob6Ey5OOEa+YoS6W5MWzyMGnAmsUE7D4XLMs5bPlf7drg
+7c2KyW0Cs5ztTn1i1H/81f30HUCM39zV8YR1KYhbQmF2j/RZTl36vyYjc/GH9G2a
+PEnP1+zVXrVdK2Hd/pjlrkY45hgYXKaCQjr+9hCKlSL9+QzDV6AlwB9rkeWc3gk0
+x+5ioGSKB0G4MBHo8tDeOq3fFWwRv6TZ6BjeOI7M2bZtUJyCFsRDbnE3MRPdMg==
+-----END CERTIFICATE-----
+`
+
+// newTLSConfig returns a TLS configuration trusting only the Freebox
+// root CAs, and the box certificate matching pin if it is not empty
+func newTLSConfig(pin string) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(freeboxRootCAs)) {
+		return nil, errors.New("unable to load the Freebox root CAs")
+	}
+
+	config := &tls.Config{
+		RootCAs: pool,
+	}
+
+	if pin != "" {
+		fingerprint, err := hex.DecodeString(strings.ReplaceAll(pin, ":", ""))
+		if err != nil {
+			return nil, errors.New("the pin must be the SHA-256 of the certificate in hex: " + err.Error())
+		}
+		config.VerifyPeerCertificate = pinVerifier(fingerprint)
+	}
+
+	return config, nil
+}
+
+// pinVerifier checks the certificate of the box against a SHA-256
+// fingerprint, on top of the verification against the root CAs
+func pinVerifier(fingerprint []byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("the Freebox did not present any certificate")
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		if !bytes.Equal(sum[:], fingerprint) {
+			return errors.New("the certificate of the Freebox does not match the pinned one: " + hex.EncodeToString(sum[:]))
+		}
+		return nil
+	}
+}
+
+// enableHTTPS switches the client to the api_domain and https_port
+// of the box discovered through /api_version
+func (c *client) enableHTTPS(pin string) error {
+	if !c.version.HTTPSAvailable || c.version.APIDomain == "" {
+		return errors.New("HTTPS access is not available on this Freebox")
+	}
+
+	config, err := newTLSConfig(pin)
+	if err != nil {
+		return err
+	}
+
+	c.endpoint = "https://" + c.version.APIDomain + ":" + strconv.Itoa(c.version.HTTPSPort) + "/"
+	c.httpClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: config,
+		},
+	}
+	c.authInf.myClient = c.httpClient
+	return nil
+}
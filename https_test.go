@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	config, err := newTLSConfig("")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if len(config.RootCAs.Subjects()) != 2 {
+		t.Error("Expected 2, but got", len(config.RootCAs.Subjects()))
+	}
+
+	if config.VerifyPeerCertificate != nil {
+		t.Error("Expected no pin verification without pin")
+	}
+
+	_, err = newTLSConfig("foobar")
+	if err == nil {
+		t.Error("Expected an err with an invalid pin, but got nil")
+	}
+}
+
+func TestPinVerifier(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	raw := ts.Certificate().Raw
+	sum := sha256.Sum256(raw)
+
+	config, err := newTLSConfig(hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	err = config.VerifyPeerCertificate([][]byte{raw}, nil)
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	err = config.VerifyPeerCertificate([][]byte{[]byte("IOI")}, nil)
+	if err == nil {
+		t.Error("Expected an err with another certificate, but got nil")
+	}
+}
+
+func TestEnableHTTPS(t *testing.T) {
+	c := newTestClient("http://127.0.0.1")
+
+	err := c.enableHTTPS("")
+	if err.Error() != "HTTPS access is not available on this Freebox" {
+		t.Error("Expected HTTPS access is not available on this Freebox, but got", err)
+	}
+
+	c.version.HTTPSAvailable = true
+	c.version.APIDomain = "foobar.fbxos.fr"
+	c.version.HTTPSPort = 4242
+
+	err = c.enableHTTPS("")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if c.endpoint != "https://foobar.fbxos.fr:4242/" {
+		t.Error("Expected https://foobar.fbxos.fr:4242/, but got", c.endpoint)
+	}
+
+	if c.authInf.httpClient() != c.httpClient {
+		t.Error("Expected the authorization to share the HTTPS client")
+	}
+}
@@ -1,310 +1,224 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/iancoleman/strcase"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	yaml "gopkg.in/yaml.v2"
 )
 
 var (
-	mafreebox string
-	listen    string
-	debug     bool
-	fiber     bool
+	mafreebox  string
+	listen     string
+	debug      bool
+	fiber      bool
+	timeout    time.Duration
+	staleGrace time.Duration
+	compat     bool
+	useHTTPS   bool
+	pin        string
+	uid        string
+	tokenSpec  string
+	configFile string
+	boxName    string
+
+	collectorFlags   = map[string]*bool{}
+	noCollectorFlags = map[string]*bool{}
 )
 
+// defaultEndpoint is used when no Freebox answers over mDNS
+const defaultEndpoint = "http://mafreebox.freebox.fr/"
+
 func init() {
-	flag.StringVar(&mafreebox, "endpoint", "http://mafreebox.freebox.fr/", "Endpoint for freebox API")
+	flag.StringVar(&mafreebox, "endpoint", "", "Endpoint for freebox API, looked up over mDNS when empty")
+	flag.StringVar(&uid, "uid", "", "uid of the Freebox to monitor when several boxes are on the network")
 	flag.StringVar(&listen, "listen", ":10001", "Prometheus metrics port")
 	flag.BoolVar(&debug, "debug", false, "Debug mode")
-	flag.BoolVar(&fiber, "fiber", false, "Turn on if you're using a fiber Freebox")
+	flag.BoolVar(&fiber, "fiber", false, "Deprecated, the WAN media is detected, same as -no-collector.xdsl")
+	flag.BoolVar(&useHTTPS, "https", false, "Reach the Freebox over HTTPS on its api_domain")
+	flag.StringVar(&pin, "pin", "", "SHA-256 fingerprint of the Freebox certificate to pin in HTTPS mode")
+	flag.StringVar(&tokenSpec, "token", "", "Where the app_token is kept: file:<path>, secret:<path> or env:<name>, file:$HOME/.freebox_token when empty")
+	flag.StringVar(&configFile, "config", "", "YAML configuration file, the flags given on the command line override it")
+	flag.StringVar(&boxName, "box", "", "Name of the box to authorize or backfill when several boxes are configured")
+	flag.DurationVar(&timeout, "timeout", 10*time.Second, "Deadline to fetch the Freebox metrics on each scrape")
+	flag.BoolVar(&compat, "compat.gauges", false, "Send the cumulative values as gauges under their former names, for the existing dashboards")
+	flag.DurationVar(&staleGrace, "stale-grace", 0, "How long to keep sending the series of a host, station or user which is gone")
+
+	for name := range collectorFactories {
+		collectorFlags[name] = flag.Bool("collector."+name, false, "Enable the "+name+" collector, whatever the WAN media")
+		noCollectorFlags[name] = flag.Bool("no-collector."+name, false, "Disable the "+name+" collector")
+	}
 }
 
 func main() {
 	flag.Parse()
 
-	if !strings.HasSuffix(mafreebox, "/") {
-		mafreebox = mafreebox + "/"
+	switch flag.Arg(0) {
+	case "discover":
+		os.Exit(runDiscover(flag.Args()[1:]))
 	}
 
-	endpoint := mafreebox + "api/v4/login/"
-	myAuthInfo := &authInfo{
-		myAPI: api{
-			login:        endpoint,
-			authz:        endpoint + "authorize/",
-			loginSession: endpoint + "session/",
-		},
-		myStore: store{location: os.Getenv("HOME") + "/.freebox_token"},
-		myApp: app{
-			AppID:      "fr.freebox.exporter",
-			AppName:    "prometheus-exporter",
-			AppVersion: "0.4",
-			DeviceName: "local",
-		},
-		myReader: bufio.NewReader(os.Stdin),
+	cfg, err := loadConfig(configFile)
+	if err == nil {
+		err = applyFlags(cfg)
 	}
-
-	myPostRequest := newPostRequest()
-
-	myConnectionXdslRequest := &postRequest{
-		method: "GET",
-		url:    mafreebox + "api/v4/connection/xdsl/",
-		header: "X-Fbx-App-Auth",
+	if err == nil {
+		err = cfg.validate()
 	}
 
-	myFreeplugRequest := &postRequest{
-		method: "GET",
-		url:    mafreebox + "api/v4/freeplug/",
-		header: "X-Fbx-App-Auth",
+	if flag.Arg(0) == "check-config" {
+		os.Exit(runCheckConfig(cfg, err))
 	}
-
-	myLanRequest := &postRequest{
-		method: "GET",
-		url:    mafreebox + "api/v4/lan/browser/pub/",
-		header: "X-Fbx-App-Auth",
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	mySystemRequest := &postRequest{
-		method: "GET",
-		url:    mafreebox + "api/v4/system/",
-		header: "X-Fbx-App-Auth",
-	}
+	listen = cfg.Listen
+	debug = cfg.Debug
+	timeout = cfg.Timeout
 
-	myWifiRequest := &postRequest{
-		method: "GET",
-		url:    mafreebox + "api/v2/wifi/ap/",
-		header: "X-Fbx-App-Auth",
-	}
+	switch flag.Arg(0) {
+	case "authorize":
+		b, err := cfg.box(boxName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		myClient, err := newBoxClient(b, cfg.App)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(runAuthorize(myClient.authInf, flag.Args()[1:]))
+	case "backfill":
+		b, err := cfg.box(boxName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		myClient, err := newBoxClient(b, cfg.App)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	myVpnRequest := &postRequest{
-		method: "GET",
-		url:    mafreebox + "api/v4/vpn/connection/",
-		header: "X-Fbx-App-Auth",
+		// the series match the ones of /metrics
+		labels := map[string]string{}
+		for name, value := range cfg.Labels.Const {
+			labels[name] = value
+		}
+		if b.Name != "" {
+			labels["box"] = b.Name
+		}
+		// the temp database is exposed by the system collector
+		metrics := append(append([]rrdMetric{}, cfg.RRD...), rrdTempMetrics...)
+		os.Exit(runBackfill(myClient, metrics, labels, flag.Args()[1:]))
+	case "":
+	default:
+		log.Fatalf("unknown command %q", flag.Arg(0))
 	}
 
-	var mySessionToken string
-
-	go func() {
-		for {
-			// There is no DSL metric on fiber Freebox
-			// If you use a fiber Freebox, use -fiber flag to turn off this metric
-			if !fiber {
-				// connectionXdsl metrics
-				connectionXdslStats, err := getConnectionXdsl(myAuthInfo, myConnectionXdslRequest, &mySessionToken)
-				if err != nil {
-					log.Printf("An error occured with connectionXdsl metrics: %v", err)
-				}
-
-				if connectionXdslStats.Success {
-					status := connectionXdslStats.Result.Status
-					result := connectionXdslStats.Result
-					down := result.Down
-					up := result.Up
-
-					connectionXdslStatusUptimeGauges.
-						WithLabelValues(status.Status, status.Protocol, status.Modulation).
-						Set(float64(status.Uptime))
-
-					connectionXdslDownAttnGauge.Set(float64(down.Attn10) / 10)
-					connectionXdslUpAttnGauge.Set(float64(up.Attn10) / 10)
-
-					// XXX: sometimes the Freebox is reporting zero as SNR which
-					// does not make sense so we don't log these
-					if down.Snr10 > 0 {
-						connectionXdslDownSnrGauge.Set(float64(down.Snr10) / 10)
-					}
-					if up.Snr10 > 0 {
-						connectionXdslUpSnrGauge.Set(float64(up.Snr10) / 10)
-					}
-
-					connectionXdslNitroGauges.WithLabelValues("down").
-						Set(bool2float(down.Nitro))
-					connectionXdslNitroGauges.WithLabelValues("up").
-						Set(bool2float(up.Nitro))
-
-					connectionXdslGinpGauges.WithLabelValues("down", "enabled").
-						Set(bool2float(down.Ginp))
-					connectionXdslGinpGauges.WithLabelValues("up", "enabled").
-						Set(bool2float(up.Ginp))
-
-					logFields(result, connectionXdslGinpGauges,
-						[]string{"rtx_tx", "rtx_c", "rtx_uc"})
-
-					logFields(result, connectionXdslErrorGauges,
-						[]string{"crc", "es", "fec", "hec", "ses"})
-				}
-
-				// dsl metrics
-				getDslResult, err := getDsl(myAuthInfo, myPostRequest, &mySessionToken)
-				if err != nil {
-					log.Printf("An error occured with DSL metrics: %v", err)
-				}
-
-				if len(getDslResult) > 0 {
-					rateUpGauge.Set(float64(getDslResult[0]))
-					rateDownGauge.Set(float64(getDslResult[1]))
-					snrUpGauge.Set(float64(getDslResult[2]))
-					snrDownGauge.Set(float64(getDslResult[3]))
-				}
-			}
-
-			// freeplug metrics
-			freeplugStats, err := getFreeplug(myAuthInfo, myFreeplugRequest, &mySessionToken)
-			if err != nil {
-				log.Printf("An error occured with freeplug metrics: %v", err)
-			}
-
-			for _, freeplugNetwork := range freeplugStats.Result {
-				for _, freeplugMember := range freeplugNetwork.Members {
-					if freeplugMember.HasNetwork {
-						freeplugHasNetworkGauge.WithLabelValues(freeplugMember.ID).Set(float64(1))
-					} else {
-						freeplugHasNetworkGauge.WithLabelValues(freeplugMember.ID).Set(float64(0))
-					}
-
-					Mb := 1e6
-					rxRate := float64(freeplugMember.RxRate) * Mb
-					txRate := float64(freeplugMember.TxRate) * Mb
-
-					if rxRate >= 0 { // -1 if not unavailable
-						freeplugRxRateGauge.WithLabelValues(freeplugMember.ID).Set(rxRate)
-					}
-
-					if txRate >= 0 { // -1 if not unavailable
-						freeplugTxRateGauge.WithLabelValues(freeplugMember.ID).Set(txRate)
-					}
-				}
-			}
-
-			// net metrics
-			getNetResult, err := getNet(myAuthInfo, myPostRequest, &mySessionToken)
-			if err != nil {
-				log.Printf("An error occured with NET metrics: %v", err)
-			}
+	clients := map[string]*client{}
+	for _, b := range cfg.Boxes {
+		myClient, err := newBoxClient(b, cfg.App)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		_, err = myClient.session(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("An error occured while opening a session with %s: %v", myClient.endpoint, err)
+		}
+		clients[b.Name] = myClient
 
-			if len(getNetResult) > 0 {
-				bwUpGauge.Set(float64(getNetResult[0]))
-				bwDownGauge.Set(float64(getNetResult[1]))
-				netRateUpGauge.Set(float64(getNetResult[2]))
-				netRateDownGauge.Set(float64(getNetResult[3]))
-				vpnRateUpGauge.Set(float64(getNetResult[4]))
-				vpnRateDownGauge.Set(float64(getNetResult[5]))
-			}
+		// in multi-box mode every metric of /metrics tells its box apart
+		cfg.registerer(prometheus.DefaultRegisterer, b.Name).
+			MustRegister(newFreeboxCollector(myClient, cfg))
+	}
 
-			// lan metrics
-			lanAvailable, err := getLan(myAuthInfo, myLanRequest, &mySessionToken)
-			if err != nil {
-				log.Printf("An error occured with LAN metrics: %v", err)
-			}
-			for _, v := range lanAvailable {
-				var Ip string
-				if len(v.L3c) > 0 {
-					Ip = v.L3c[0].Addr
-				} else {
-					Ip = ""
-				}
-				if v.Reachable {
-					lanReachableGauges.With(prometheus.Labels{"name": v.PrimaryName, "vendor":v.Vendor_name, "ip": Ip}).Set(float64(1))
-				} else {
-					lanReachableGauges.With(prometheus.Labels{"name": v.PrimaryName, "vendor":v.Vendor_name, "ip": Ip}).Set(float64(0))
-				}
-			}
+	log.Println("freebox_exporter started on port", listen)
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			ErrorLog:      log.New(os.Stderr, "", log.LstdFlags),
+			ErrorHandling: promhttp.ContinueOnError,
+		}),
+	))
+	if cfg.multiBox() {
+		http.Handle("/probe", probeHandler(clients, cfg))
+	}
+	log.Fatal(http.ListenAndServe(listen, nil))
+}
 
-			// system metrics
-			systemStats, err := getSystem(myAuthInfo, mySystemRequest, &mySessionToken)
-			if err != nil {
-				log.Printf("An error occured with System metrics: %v", err)
+// applyFlags overrides the configuration with the flags given on the
+// command line, the ones about the box require a single box
+func applyFlags(cfg *config) error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Listen = listen
+		case "debug":
+			cfg.Debug = debug
+		case "timeout":
+			cfg.Timeout = timeout
+		case "stale-grace":
+			cfg.StaleGrace = staleGrace
+		case "compat.gauges":
+			cfg.CompatGauges = compat
+		case "fiber":
+			log.Println("-fiber is deprecated, the WAN media is detected, use -no-collector.xdsl to turn off the DSL metrics")
+			if fiber {
+				cfg.setEnabled("xdsl", false)
 			}
-
-			systemTempGauges.WithLabelValues("Température CPU B").Set(float64(systemStats.Result.TempCpub))
-			systemTempGauges.WithLabelValues("Température CPU M").Set(float64(systemStats.Result.TempCpum))
-			systemTempGauges.WithLabelValues("Température Switch").Set(float64(systemStats.Result.TempSW))
-			systemTempGauges.WithLabelValues("Disque dur").Set(float64(systemStats.Result.TempHDD))
-			systemFanGauges.WithLabelValues("Ventilateur 1").Set(float64(systemStats.Result.FanRPM))
-
-			systemUptimeGauges.
-				WithLabelValues(systemStats.Result.FirmwareVersion).
-				Set(float64(systemStats.Result.UptimeVal))
-
-			// wifi metrics
-			wifiStats, err := getWifi(myAuthInfo, myWifiRequest, &mySessionToken)
-			if err != nil {
-				log.Printf("An error occured with Wifi metrics: %v", err)
+		case "endpoint", "uid", "token", "https", "pin":
+			if len(cfg.Boxes) != 1 {
+				err = fmt.Errorf("-%s cannot be used when several boxes are configured", f.Name)
+				return
 			}
-			for _, accessPoint := range wifiStats.Result {
-				myWifiStationRequest := &postRequest{
-					method: "GET",
-					url:    mafreebox + "api/v2/wifi/ap/" + strconv.Itoa(accessPoint.ID) + "/stations",
-					header: "X-Fbx-App-Auth",
-				}
-				wifiStationsStats, err := getWifiStations(myAuthInfo, myWifiStationRequest, &mySessionToken)
-				if err != nil {
-					log.Printf("An error occured with Wifi station metrics: %v", err)
-				}
-				for _, station := range wifiStationsStats.Result {
-					wifiSignalGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.Signal))
-					wifiInactiveGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.Inactive))
-					wifiConnectionDurationGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.ConnectionDuration))
-					wifiRXBytesGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.RXBytes))
-					wifiTXBytesGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.TXBytes))
-					wifiRXRateGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.RXRate))
-					wifiTXRateGauges.With(prometheus.Labels{"access_point": accessPoint.Name, "hostname": station.Hostname, "state": station.State}).Set(float64(station.TXRate))
-				}
+			b := &cfg.Boxes[0]
+			switch f.Name {
+			case "endpoint":
+				b.Endpoint = mafreebox
+			case "uid":
+				b.UID = uid
+			case "token":
+				b.Token = tokenSpec
+			case "https":
+				b.HTTPS = useHTTPS
+			case "pin":
+				b.Pin = pin
 			}
-
-			// VPN Server Connections List
-			getVpnServerResult, err := getVpnServer(myAuthInfo, myVpnRequest, &mySessionToken)
-			if err != nil {
-				log.Printf("An error occured with VPN station metrics: %v", err)
+		default:
+			if name := strings.TrimPrefix(f.Name, "collector."); name != f.Name {
+				cfg.setEnabled(name, *collectorFlags[name])
 			}
-			for _, connection := range getVpnServerResult.Result {
-				vpnServerConnectionsList.With(prometheus.Labels{"user": connection.User, "vpn": connection.Vpn, "src_ip": connection.SrcIP, "local_ip": connection.LocalIP, "name": "rx_bytes"}).Set(float64(connection.RxBytes))
-				vpnServerConnectionsList.With(prometheus.Labels{"user": connection.User, "vpn": connection.Vpn, "src_ip": connection.SrcIP, "local_ip": connection.LocalIP, "name": "tx_bytes"}).Set(float64(connection.TxBytes))
+			if name := strings.TrimPrefix(f.Name, "no-collector."); name != f.Name {
+				cfg.setEnabled(name, !*noCollectorFlags[name])
 			}
-
-			time.Sleep(10 * time.Second)
 		}
-	}()
-
-	log.Println("freebox_exporter started on port", listen)
-	http.Handle("/metrics", promhttp.Handler())
-	log.Fatal(http.ListenAndServe(listen, nil))
+	})
+	return err
 }
 
-func logFields(result interface{}, gauge *prometheus.GaugeVec, fields []string) error {
-	resultReflect := reflect.ValueOf(result)
-
-	for _, direction := range []string{"down", "up"} {
-		for _, field := range fields {
-			value := reflect.Indirect(resultReflect).
-				FieldByName(strcase.ToCamel(direction)).
-				FieldByName(strcase.ToCamel(field))
-
-			if value.IsZero() {
-				continue
-			}
-
-			gauge.WithLabelValues(direction, field).
-				Set(float64(value.Int()))
-		}
+// runCheckConfig implements the check-config command which validates
+// the configuration and prints it once every override has been applied
+func runCheckConfig(cfg *config, err error) int {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
 	}
 
-	return nil
-}
-
-func bool2float(b bool) float64 {
-	if b {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
 		return 1
 	}
+	fmt.Print(string(data))
 	return 0
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/mdns"
+)
+
+// freeboxService is the mDNS service announced by every Freebox
+const freeboxService = "_fbx-api._tcp"
+
+// box is a Freebox found on the local network through mDNS
+type box struct {
+	UID        string
+	DeviceType string
+	APIDomain  string
+	APIBaseURL string
+	APIVersion string
+	HTTPSPort  int
+	Addr       net.IP
+	Port       int
+}
+
+// newBox reads the TXT records of a Freebox announce
+func newBox(entry *mdns.ServiceEntry) box {
+	b := box{
+		Addr: entry.AddrV4,
+		Port: entry.Port,
+	}
+	if b.Addr == nil {
+		b.Addr = entry.AddrV6
+	}
+
+	for _, field := range entry.InfoFields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "uid":
+			b.UID = kv[1]
+		case "device_type":
+			b.DeviceType = kv[1]
+		case "api_domain":
+			b.APIDomain = kv[1]
+		case "api_base_url":
+			b.APIBaseURL = kv[1]
+		case "api_version":
+			b.APIVersion = kv[1]
+		case "https_port":
+			b.HTTPSPort, _ = strconv.Atoi(kv[1])
+		}
+	}
+	return b
+}
+
+// endpoint returns the plain HTTP endpoint of the box on the LAN
+func (b box) endpoint() string {
+	return "http://" + net.JoinHostPort(b.Addr.String(), strconv.Itoa(b.Port)) + "/"
+}
+
+// browse lists the Freeboxes answering on the local network within wait
+func browse(wait time.Duration) ([]box, error) {
+	entries := make(chan *mdns.ServiceEntry, 16)
+	boxes := []box{}
+	done := make(chan struct{})
+	go func() {
+		seen := map[string]bool{}
+		for entry := range entries {
+			if !strings.Contains(entry.Name, freeboxService) {
+				continue
+			}
+			b := newBox(entry)
+			if b.Addr == nil || seen[b.UID+b.endpoint()] {
+				continue
+			}
+			seen[b.UID+b.endpoint()] = true
+			boxes = append(boxes, b)
+		}
+		close(done)
+	}()
+
+	params := mdns.DefaultParams(freeboxService)
+	params.Timeout = wait
+	params.Entries = entries
+	err := mdns.Query(params)
+	close(entries)
+	<-done
+
+	return boxes, err
+}
+
+// findBox picks the box matching uid, or the only box found when uid
+// is empty
+func findBox(boxes []box, uid string) (box, error) {
+	if uid != "" {
+		for _, b := range boxes {
+			if b.UID == uid {
+				return b, nil
+			}
+		}
+		return box{}, errors.New("no Freebox found with uid " + uid)
+	}
+
+	switch len(boxes) {
+	case 0:
+		return box{}, errors.New("no Freebox found on the local network")
+	case 1:
+		return boxes[0], nil
+	}
+	return box{}, fmt.Errorf("%d Freeboxes found on the local network, use -uid to pick one", len(boxes))
+}
+
+// runDiscover implements the discover command which lists every
+// Freebox found on the local network
+func runDiscover(args []string) int {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	wait := flags.Duration("wait", 3*time.Second, "How long to wait for the Freeboxes to answer")
+	flags.Parse(args)
+
+	boxes, err := browse(*wait)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "An error occured while browsing the local network:", err)
+		return 1
+	}
+	if len(boxes) == 0 {
+		fmt.Fprintln(os.Stderr, "no Freebox found on the local network")
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UID\tDEVICE TYPE\tENDPOINT\tAPI DOMAIN\tHTTPS PORT\tAPI VERSION")
+	for _, b := range boxes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			b.UID, b.DeviceType, b.endpoint(), b.APIDomain, b.HTTPSPort, b.APIVersion)
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hashicorp/mdns"
+)
+
+func TestNewBox(t *testing.T) {
+	entry := &mdns.ServiceEntry{
+		Name:   "Freebox Server._fbx-api._tcp.local.",
+		AddrV4: net.ParseIP("192.168.1.254"),
+		Port:   80,
+		InfoFields: []string{
+			"api_version=8.0",
+			"device_type=FreeboxServer7,1",
+			"api_base_url=/api/",
+			"uid=0123456789abcdef",
+			"api_domain=foobar.fbxos.fr",
+			"https_available=1",
+			"https_port=4242",
+			"foobar",
+		},
+	}
+
+	b := newBox(entry)
+
+	if b.UID != "0123456789abcdef" {
+		t.Error("Expected 0123456789abcdef, but got", b.UID)
+	}
+
+	if b.APIDomain != "foobar.fbxos.fr" || b.HTTPSPort != 4242 {
+		t.Errorf("Expected foobar.fbxos.fr 4242, but got %v %v", b.APIDomain, b.HTTPSPort)
+	}
+
+	if b.endpoint() != "http://192.168.1.254:80/" {
+		t.Error("Expected http://192.168.1.254:80/, but got", b.endpoint())
+	}
+}
+
+func TestFindBox(t *testing.T) {
+	boxes := []box{
+		{UID: "IOI", Addr: net.ParseIP("192.168.1.254"), Port: 80},
+		{UID: "OIO", Addr: net.ParseIP("192.168.2.254"), Port: 80},
+	}
+
+	_, err := findBox(nil, "")
+	if err.Error() != "no Freebox found on the local network" {
+		t.Error("Expected no Freebox found on the local network, but got", err)
+	}
+
+	_, err = findBox(boxes, "")
+	if err.Error() != "2 Freeboxes found on the local network, use -uid to pick one" {
+		t.Error("Expected 2 Freeboxes found on the local network, use -uid to pick one, but got", err)
+	}
+
+	b, err := findBox(boxes, "OIO")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if b.endpoint() != "http://192.168.2.254:80/" {
+		t.Error("Expected http://192.168.2.254:80/, but got", b.endpoint())
+	}
+
+	_, err = findBox(boxes, "foobar")
+	if err.Error() != "no Freebox found with uid foobar" {
+		t.Error("Expected no Freebox found with uid foobar, but got", err)
+	}
+
+	b, err = findBox(boxes[:1], "")
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if b.UID != "IOI" {
+		t.Error("Expected IOI, but got", b.UID)
+	}
+}
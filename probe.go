@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler serves the metrics of the box given by the target
+// parameter, the way blackbox_exporter probes its targets
+func probeHandler(clients map[string]*client, cfg *config) http.HandlerFunc {
+	// the collectors outlive a probe to keep their schedule and the
+	// series in their grace period
+	collectors := map[string]*freeboxCollector{}
+	for name, c := range clients {
+		collectors[name] = newFreeboxCollector(c, cfg)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		f, ok := collectors[target]
+		if !ok {
+			http.Error(w, "unknown target "+target, http.StatusNotFound)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		cfg.registerer(registry, "").MustRegister(f)
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorLog:      log.New(os.Stderr, "", log.LstdFlags),
+			ErrorHandling: promhttp.ContinueOnError,
+		}).ServeHTTP(w, r)
+	}
+}
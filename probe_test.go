@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, system{FanRPM: 666})
+	}))
+	defer ts.Close()
+
+	handler := probeHandler(map[string]*client{"paris": newTestClient(ts.URL)}, defaultConfig())
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/probe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Error("Expected 400, but got", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/probe?target=lyon", nil))
+	if w.Code != http.StatusNotFound {
+		t.Error("Expected 404, but got", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/probe?target=paris", nil))
+	if w.Code != http.StatusOK {
+		t.Error("Expected 200, but got", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), `freebox_system_fan_rpm{name="Ventilateur 1"} 666`) {
+		t.Error("Expected the fan speed of paris, but got", w.Body.String())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rrdPageSize is the number of points asked at once to the RRD
+const rrdPageSize = 100
+
+// rrdPrecisions are the resolutions of the RRD databases (in seconds),
+// from the finest to the coarsest
+var rrdPrecisions = []int{10, 60, 300, 3600}
+
+// rrdMetric maps a field of an RRD database to a metric: the name gets
+// the unit as a suffix, the value is multiplied by the scale (1 when not
+// set) and the type is gauge unless told otherwise
+type rrdMetric struct {
+	DB     string            `yaml:"db"`
+	Field  string            `yaml:"field"`
+	Name   string            `yaml:"name"`
+	Help   string            `yaml:"help,omitempty"`
+	Unit   string            `yaml:"unit,omitempty"`
+	Scale  float64           `yaml:"scale,omitempty"`
+	Type   string            `yaml:"type,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// fullName returns the name of the metric, with the unit as a suffix
+func (m rrdMetric) fullName() string {
+	if m.Unit == "" || strings.HasSuffix(m.Name, "_"+m.Unit) || strings.HasSuffix(m.Name, "_"+m.Unit+"_total") {
+		return m.Name
+	}
+	if strings.HasSuffix(m.Name, "_total") {
+		return strings.TrimSuffix(m.Name, "_total") + "_" + m.Unit + "_total"
+	}
+	return m.Name + "_" + m.Unit
+}
+
+// valueType returns the Prometheus type of the metric
+func (m rrdMetric) valueType() prometheus.ValueType {
+	if m.Type == "counter" {
+		return prometheus.CounterValue
+	}
+	return prometheus.GaugeValue
+}
+
+// value scales the raw value of the field
+func (m rrdMetric) value(raw int64) float64 {
+	if m.Scale == 0 {
+		return float64(raw)
+	}
+	return float64(raw) * m.Scale
+}
+
+// labelNames returns the sorted names of the labels of the metric
+func (m rrdMetric) labelNames() []string {
+	names := make([]string, 0, len(m.Labels))
+	for name := range m.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate checks a metric of the rrd section of the configuration
+func (m rrdMetric) validate() error {
+	if m.DB == "" || m.Field == "" || m.Name == "" {
+		return errors.New("the db, the field and the name are required")
+	}
+	if !labelNameRE.MatchString(m.fullName()) {
+		return fmt.Errorf("invalid metric name %q", m.fullName())
+	}
+	switch m.Type {
+	case "", "gauge":
+	case "counter":
+		if !strings.HasSuffix(m.fullName(), "_total") {
+			return fmt.Errorf("the counter %q must end with _total", m.fullName())
+		}
+	default:
+		return fmt.Errorf("unknown type %q, expected gauge or counter", m.Type)
+	}
+	for name := range m.Labels {
+		if !labelNameRE.MatchString(name) {
+			return fmt.Errorf("invalid label %q", name)
+		}
+	}
+	return nil
+}
+
+// rrdMetrics are the RRD fields exposed by the rrd collector unless the
+// configuration has its own rrd section, with the names the exporter
+// always used for them
+var rrdMetrics = []rrdMetric{
+	{DB: "net", Field: "bw_up", Name: "freebox_net_bw_up_bytes", Help: "Upload available bandwidth (in byte/s)"},
+	{DB: "net", Field: "bw_down", Name: "freebox_net_bw_down_bytes", Help: "Download available bandwidth (in byte/s)"},
+	{DB: "net", Field: "rate_up", Name: "freebox_net_up_bytes", Help: "Upload rate (in byte/s)"},
+	{DB: "net", Field: "rate_down", Name: "freebox_net_down_bytes", Help: "Download rate (in byte/s)"},
+	{DB: "net", Field: "vpn_rate_up", Name: "freebox_net_vpn_up_bytes", Help: "Vpn client upload rate (in byte/s)"},
+	{DB: "net", Field: "vpn_rate_down", Name: "freebox_net_vpn_down_bytes", Help: "Vpn client download rate (in byte/s)"},
+
+	{DB: "dsl", Field: "rate_up", Name: "freebox_dsl_up_bytes", Help: "Available upload bandwidth (in byte/s)"},
+	{DB: "dsl", Field: "rate_down", Name: "freebox_dsl_down_bytes", Help: "Available download bandwidth (in byte/s)"},
+	{DB: "dsl", Field: "snr_up", Name: "freebox_dsl_snr_up_decibel", Help: "Upload signal/noise ratio (in 1/10 dB)"},
+	{DB: "dsl", Field: "snr_down", Name: "freebox_dsl_snr_down_decibel", Help: "Download signal/noise ratio (in 1/10 dB)"},
+
+	{DB: "switch", Field: "rx_1", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "1", "direction": "rx"}},
+	{DB: "switch", Field: "tx_1", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "1", "direction": "tx"}},
+	{DB: "switch", Field: "rx_2", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "2", "direction": "rx"}},
+	{DB: "switch", Field: "tx_2", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "2", "direction": "tx"}},
+	{DB: "switch", Field: "rx_3", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "3", "direction": "rx"}},
+	{DB: "switch", Field: "tx_3", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "3", "direction": "tx"}},
+	{DB: "switch", Field: "rx_4", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "4", "direction": "rx"}},
+	{DB: "switch", Field: "tx_4", Name: "freebox_switch_port_rate_bytes_per_second", Help: "Rate of a switch port from the RRD (in byte/s)", Labels: map[string]string{"port": "4", "direction": "tx"}},
+}
+
+// rrdTempMetrics are the fields of the temp database, only backfilled
+// since the system collector already exposes them
+var rrdTempMetrics = []rrdMetric{
+	{DB: "temp", Field: "cpum", Name: "freebox_system_temp_celsius", Help: "Temperature sensors reported by system (in °C)", Labels: map[string]string{"name": "Température CPU M"}},
+	{DB: "temp", Field: "cpub", Name: "freebox_system_temp_celsius", Help: "Temperature sensors reported by system (in °C)", Labels: map[string]string{"name": "Température CPU B"}},
+	{DB: "temp", Field: "sw", Name: "freebox_system_temp_celsius", Help: "Temperature sensors reported by system (in °C)", Labels: map[string]string{"name": "Température Switch"}},
+	{DB: "temp", Field: "hdd", Name: "freebox_system_temp_celsius", Help: "Temperature sensors reported by system (in °C)", Labels: map[string]string{"name": "Disque dur"}},
+	{DB: "temp", Field: "fan_speed", Name: "freebox_system_fan_rpm", Help: "Fan speed reported by system (in RPM)", Labels: map[string]string{"name": "Ventilateur 1"}},
+}
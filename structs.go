@@ -1,18 +1,36 @@
 package main
 
-import "bufio"
+import "net/http"
+
+// https://dev.freebox.fr/sdk/os/login/
+type apiVersion struct {
+	UID            string `json:"uid,omitempty"`
+	DeviceName     string `json:"device_name,omitempty"`
+	DeviceType     string `json:"device_type,omitempty"`
+	APIVersion     string `json:"api_version"`
+	APIBaseURL     string `json:"api_base_url"`
+	APIDomain      string `json:"api_domain,omitempty"`
+	HTTPSAvailable bool   `json:"https_available,omitempty"`
+	HTTPSPort      int    `json:"https_port,omitempty"`
+	BoxModel       string `json:"box_model,omitempty"`
+	BoxModelName   string `json:"box_model_name,omitempty"`
+}
 
 type track struct {
-	Success bool `json:"success"`
-	Result  struct {
+	Success   bool   `json:"success"`
+	Msg       string `json:"msg,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Result    struct {
 		AppToken string `json:"app_token"`
 		TrackID  int    `json:"track_id"`
 	} `json:"result"`
 }
 
 type grant struct {
-	Success bool `json:"success"`
-	Result  struct {
+	Success   bool   `json:"success"`
+	Msg       string `json:"msg,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Result    struct {
 		Status    string `json:"status"`
 		Challenge string `json:"challenge"`
 	} `json:"result"`
@@ -54,72 +72,147 @@ type sessionToken struct {
 }
 
 type rrd struct {
-	UID     string `json:"uid,omitempty"`
-	Success bool   `json:"success"`
-	Msg     string `json:"msg,omitempty"`
-	Result  struct {
-		DateStart int                `json:"date_start,omitempty"`
-		DateEnd   int                `json:"date_end,omitempty"`
-		Data      []map[string]int64 `json:"data,omitempty"`
-	} `json:"result"`
-	ErrorCode string `json:"error_code"`
+	DateStart int                `json:"date_start,omitempty"`
+	DateEnd   int                `json:"date_end,omitempty"`
+	Data      []map[string]int64 `json:"data,omitempty"`
 }
 
 // https://dev.freebox.fr/sdk/os/connection/
+type connectionStatus struct {
+	State         string `json:"state"` // up|going_up|down|going_down
+	Type          string `json:"type"`  // ethernet|rfc2684|pppoatm
+	Media         string `json:"media"` // ftth|ethernet|xdsl|backup_4g
+	IPv4          string `json:"ipv4"`
+	IPv6          string `json:"ipv6"`
+	RateUp        int64  `json:"rate_up"`        // in byte/s
+	RateDown      int64  `json:"rate_down"`      // in byte/s
+	BandwidthUp   int64  `json:"bandwidth_up"`   // in bit/s
+	BandwidthDown int64  `json:"bandwidth_down"` // in bit/s
+	BytesUp       int64  `json:"bytes_up"`
+	BytesDown     int64  `json:"bytes_down"`
+}
+
+type connectionConfig struct {
+	Ping              bool   `json:"ping"`
+	IsSecurePass      bool   `json:"is_secure_pass"`
+	RemoteAccess      bool   `json:"remote_access"`
+	RemoteAccessPort  int    `json:"remote_access_port"`
+	RemoteAccessIP    string `json:"remote_access_ip"`
+	APIRemoteAccess   bool   `json:"api_remote_access"`
+	Wol               bool   `json:"wol"`
+	Adblock           bool   `json:"adblock"`
+	AllowTokenRequest bool   `json:"allow_token_request"`
+}
+
+type connectionIPv6Config struct {
+	IPv6Enabled  bool   `json:"ipv6_enabled"`
+	IPv6Firewall bool   `json:"ipv6_firewall"`
+	IPv6LL       string `json:"ipv6ll"`
+	Delegations  []struct {
+		Prefix  string `json:"prefix"`
+		NextHop string `json:"next_hop"`
+	} `json:"delegations"`
+}
+
+type ddnsStatus struct {
+	Status          string `json:"status"`
+	NextRepeat      int64  `json:"next_repeat"`
+	NextRepeatDelay int64  `json:"next_repeat_delay"`
+	LastRefresh     int64  `json:"last_refresh"`
+	LastError       int64  `json:"last_error"`
+}
+
+type connectionFtth struct {
+	SfpPresent        bool   `json:"sfp_present"`
+	SfpAlimOk         bool   `json:"sfp_alim_ok"`
+	SfpHasPowerReport bool   `json:"sfp_has_power_report"`
+	SfpHasSignal      bool   `json:"sfp_has_signal"`
+	Link              bool   `json:"link"`
+	SfpSerial         string `json:"sfp_serial"`
+	SfpModel          string `json:"sfp_model"`
+	SfpVendor         string `json:"sfp_vendor"`
+	SfpPwrTx          int    `json:"sfp_pwr_tx"` // in 1/100 dBm
+	SfpPwrRx          int    `json:"sfp_pwr_rx"` // in 1/100 dBm
+}
+
 type connectionXdsl struct {
-	Success bool `json:"success"`
-	Result  struct {
-		Status struct {
-			Status     string `json:"status"`
-			Modulation string `json:"modulation"`
-			Protocol   string `json:"protocol"`
-			Uptime     int    `json:"uptime"`
-		} `json:"status"`
-		Down struct {
-			Attn       int    `json:"attn"`
-			Attn10     int    `json:"attn_10"`
-			Crc        int    `json:"crc"`
-			Es         int    `json:"es"`
-			Fec        int    `json:"fec"`
-			Ginp       bool   `json:"ginp"`
-			Hec        int    `json:"hec"`
-			Maxrate    uint64 `json:"maxrate"`
-			Nitro      bool   `json:"nitro"`
-			Phyr       bool   `json:"phyr"`
-			Rate       int    `json:"rate"`
-			RtxC       int    `json:"rtx_c,omitempty"`
-			RtxTx      int    `json:"rtx_tx,omitempty"`
-			RtxUc      int    `json:"rtx_uc,omitempty"`
-			Rxmt       int    `json:"rxmt"`
-			RxmtCorr   int    `json:"rxmt_corr"`
-			RxmtUncorr int    `json:"rxmt_uncorr"`
-			Ses        int    `json:"ses"`
-			Snr        int    `json:"snr"`
-			Snr10      int    `json:"snr_10"`
-		} `json:"down"`
-		Up struct {
-			Attn       int    `json:"attn"`
-			Attn10     int    `json:"attn_10"`
-			Crc        int    `json:"crc"`
-			Es         int    `json:"es"`
-			Fec        int    `json:"fec"`
-			Ginp       bool   `json:"ginp"`
-			Hec        int    `json:"hec"`
-			Maxrate    uint64 `json:"maxrate"`
-			Nitro      bool   `json:"nitro"`
-			Phyr       bool   `json:"phyr"`
-			Rate       uint64 `json:"rate"`
-			RtxC       int    `json:"rtx_c,omitempty"`
-			RtxTx      int    `json:"rtx_tx,omitempty"`
-			RtxUc      int    `json:"rtx_uc,omitempty"`
-			Rxmt       int    `json:"rxmt"`
-			RxmtCorr   int    `json:"rxmt_corr"`
-			RxmtUncorr int    `json:"rxmt_uncorr"`
-			Ses        int    `json:"ses"`
-			Snr        int    `json:"snr"`
-			Snr10      int    `json:"snr_10"`
-		} `json:"up"`
-	}
+	Status struct {
+		Status     string `json:"status"`
+		Modulation string `json:"modulation"`
+		Protocol   string `json:"protocol"`
+		Uptime     int    `json:"uptime"`
+	} `json:"status"`
+	Down struct {
+		Attn       int    `json:"attn"`
+		Attn10     int    `json:"attn_10"`
+		Crc        int    `json:"crc"`
+		Es         int    `json:"es"`
+		Fec        int    `json:"fec"`
+		Ginp       bool   `json:"ginp"`
+		Hec        int    `json:"hec"`
+		Maxrate    uint64 `json:"maxrate"`
+		Nitro      bool   `json:"nitro"`
+		Phyr       bool   `json:"phyr"`
+		Rate       int    `json:"rate"`
+		RtxC       int    `json:"rtx_c,omitempty"`
+		RtxTx      int    `json:"rtx_tx,omitempty"`
+		RtxUc      int    `json:"rtx_uc,omitempty"`
+		Rxmt       int    `json:"rxmt"`
+		RxmtCorr   int    `json:"rxmt_corr"`
+		RxmtUncorr int    `json:"rxmt_uncorr"`
+		Ses        int    `json:"ses"`
+		Snr        int    `json:"snr"`
+		Snr10      int    `json:"snr_10"`
+	} `json:"down"`
+	Up struct {
+		Attn       int    `json:"attn"`
+		Attn10     int    `json:"attn_10"`
+		Crc        int    `json:"crc"`
+		Es         int    `json:"es"`
+		Fec        int    `json:"fec"`
+		Ginp       bool   `json:"ginp"`
+		Hec        int    `json:"hec"`
+		Maxrate    uint64 `json:"maxrate"`
+		Nitro      bool   `json:"nitro"`
+		Phyr       bool   `json:"phyr"`
+		Rate       uint64 `json:"rate"`
+		RtxC       int    `json:"rtx_c,omitempty"`
+		RtxTx      int    `json:"rtx_tx,omitempty"`
+		RtxUc      int    `json:"rtx_uc,omitempty"`
+		Rxmt       int    `json:"rxmt"`
+		RxmtCorr   int    `json:"rxmt_corr"`
+		RxmtUncorr int    `json:"rxmt_uncorr"`
+		Ses        int    `json:"ses"`
+		Snr        int    `json:"snr"`
+		Snr10      int    `json:"snr_10"`
+	} `json:"up"`
+}
+
+// https://dev.freebox.fr/sdk/os/switch/
+type switchPortStatus struct {
+	ID     int    `json:"id"`
+	Link   string `json:"link"`   // up|down
+	Mode   string `json:"mode"`   // 1000BaseT-FD...
+	Speed  string `json:"speed"`  // in Mbit/s
+	Duplex string `json:"duplex"` // half|full
+}
+
+type switchPortConfig struct {
+	ID      int `json:"id"`
+	MacList []struct {
+		Mac      string `json:"mac"`
+		Hostname string `json:"hostname"`
+	} `json:"mac_list"`
+}
+
+type switchPortStats struct {
+	RxGoodBytes   int64 `json:"rx_good_bytes"`
+	RxGoodPackets int64 `json:"rx_good_packets"`
+	RxErrPackets  int64 `json:"rx_err_packets"`
+	TxBytes       int64 `json:"tx_bytes"`
+	TxPackets     int64 `json:"tx_packets"`
+	TxFcs         int64 `json:"tx_fcs"`
+	TxCollisions  int64 `json:"tx_collisions"`
 }
 
 type database struct {
@@ -131,11 +224,6 @@ type database struct {
 }
 
 // https://dev.freebox.fr/sdk/os/freeplug/
-type freeplug struct {
-	Success bool              `json:"success"`
-	Result  []freeplugNetwork `json:"result"`
-}
-
 type freeplugNetwork struct {
 	ID      string           `json:"id"`
 	Members []freeplugMember `json:"members"`
@@ -158,20 +246,113 @@ type freeplugMember struct {
 
 // https://dev.freebox.fr/sdk/os/lan/
 type l3c struct {
-	Addr string `json:"addr,omitempty"`
+	Addr              string `json:"addr,omitempty"`
+	Af                string `json:"af,omitempty"` // ipv4|ipv6
+	Active            bool   `json:"active,omitempty"`
+	Reachable         bool   `json:"reachable,omitempty"`
+	LastActivity      int64  `json:"last_activity,omitempty"`
+	LastTimeReachable int64  `json:"last_time_reachable,omitempty"`
+}
+
+type l2Ident struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type,omitempty"` // mac_address
 }
 
 type lanHost struct {
-	Reachable   bool   `json:"reachable,omitempty"`
-	PrimaryName string `json:"primary_name,omitempty"`
-	Vendor_name string `json:"vendor_name,omitempty"`
-	L3c         []l3c  `json:"l3connectivities,omitempty"`
+	ID                string  `json:"id,omitempty"`
+	Reachable         bool    `json:"reachable,omitempty"`
+	Active            bool    `json:"active,omitempty"`
+	PrimaryName       string  `json:"primary_name,omitempty"`
+	HostType          string  `json:"host_type,omitempty"`
+	Vendor_name       string  `json:"vendor_name,omitempty"`
+	LastActivity      int64   `json:"last_activity,omitempty"`
+	FirstActivity     int64   `json:"first_activity,omitempty"`
+	LastTimeReachable int64   `json:"last_time_reachable,omitempty"`
+	L2Ident           l2Ident `json:"l2ident,omitempty"`
+	L3c               []l3c   `json:"l3connectivities,omitempty"`
+}
+
+type lanInterface struct {
+	Name      string `json:"name,omitempty"` // pub|wifiguest
+	HostCount int    `json:"host_count,omitempty"`
+}
+
+// https://dev.freebox.fr/sdk/os/dhcp/
+type dhcpConfig struct {
+	Enabled         bool     `json:"enabled"`
+	StickyAssign    bool     `json:"sticky_assign"`
+	Gateway         string   `json:"gateway"`
+	Netmask         string   `json:"netmask"`
+	IPRangeStart    string   `json:"ip_range_start"`
+	IPRangeEnd      string   `json:"ip_range_end"`
+	AlwaysBroadcast bool     `json:"always_broadcast"`
+	DNS             []string `json:"dns"`
+}
+
+type dhcpv6Config struct {
+	Enabled      bool     `json:"enabled"`
+	UseCustomDNS bool     `json:"use_custom_dns"`
+	DNS          []string `json:"dns"`
+}
+
+type dhcpStaticLease struct {
+	ID       string  `json:"id"`
+	Mac      string  `json:"mac"`
+	Comment  string  `json:"comment"`
+	Hostname string  `json:"hostname"`
+	IP       string  `json:"ip"`
+	Host     lanHost `json:"host"`
+}
+
+type dhcpDynamicLease struct {
+	Mac            string  `json:"mac"`
+	Hostname       string  `json:"hostname"`
+	IP             string  `json:"ip"`
+	LeaseRemaining int64   `json:"lease_remaining"`
+	AssignTime     int64   `json:"assign_time"`
+	RefreshTime    int64   `json:"refresh_time"`
+	IsStatic       bool    `json:"is_static"`
+	Host           lanHost `json:"host"`
+}
+
+// https://dev.freebox.fr/sdk/os/fw/
+type fwRedir struct {
+	ID           int    `json:"id"`
+	Enabled      bool   `json:"enabled"`
+	IPProto      string `json:"ip_proto"` // tcp|udp
+	WanPortStart int    `json:"wan_port_start"`
+	WanPortEnd   int    `json:"wan_port_end"`
+	LanIP        string `json:"lan_ip"`
+	LanPort      int    `json:"lan_port"`
+	Hostname     string `json:"hostname"`
+	SrcIP        string `json:"src_ip"`
+	Comment      string `json:"comment"`
+}
+
+type fwIncoming struct {
+	ID      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+	Type    string `json:"type"` // tcp|udp|tcp_udp
+	InPort  int    `json:"in_port"`
+}
+
+type fwDmz struct {
+	Enabled bool   `json:"enabled"`
+	IP      string `json:"ip"`
 }
 
-type lan struct {
-	Success   bool      `json:"success"`
-	Result    []lanHost `json:"result"`
-	ErrorCode string    `json:"error_code"`
+// https://dev.freebox.fr/sdk/os/igd/
+type upnpRedir struct {
+	ID        string `json:"id"`
+	Enabled   bool   `json:"enabled"`
+	ExtSrcIP  string `json:"ext_src_ip"`
+	ExtPort   int    `json:"ext_port"`
+	IntIP     string `json:"int_ip"`
+	IntPort   int    `json:"int_port"`
+	Proto     string `json:"proto"` // tcp|udp
+	Desc      string `json:"desc"`
+	Remaining int64  `json:"remaining"`
 }
 
 type idNameValue struct {
@@ -182,60 +363,153 @@ type idNameValue struct {
 
 // https://dev.freebox.fr/sdk/os/system/
 type system struct {
-	Success bool `json:"success"`
-	Result  struct {
-		Mac              string `json:"mac,omitempty"`
-		FanRPM           int    `json:"fan_rpm,omitempty"`
-		BoxFlavor        string `json:"box_flavor,omitempty"`
-		TempCpub         int    `json:"temp_cpub,omitempty"`
-		TempCpum         int    `json:"temp_cpum,omitempty"`
-		DiskStatus       string `json:"disk_status,omitempty"`
-		TempHDD          int    `json:"temp_hdd,omitempty"`
-		BoardName        string `json:"board_name,omitempty"`
-		TempSW           int    `json:"temp_sw,omitempty"`
-		Uptime           string `json:"uptime,omitempty"`
-		UptimeVal        int    `json:"uptime_val,omitempty"`
-		UserMainStorage  string `json:"user_main_storage,omitempty"`
-		BoxAuthenticated bool   `json:"box_authenticated,omitempty"`
-		Serial           string `json:"serial,omitempty"`
-		FirmwareVersion  string `json:"firmware_version,omitempty"`
-	}
+	Mac              string `json:"mac,omitempty"`
+	FanRPM           int    `json:"fan_rpm,omitempty"`
+	BoxFlavor        string `json:"box_flavor,omitempty"`
+	TempCpub         int    `json:"temp_cpub,omitempty"`
+	TempCpum         int    `json:"temp_cpum,omitempty"`
+	DiskStatus       string `json:"disk_status,omitempty"`
+	TempHDD          int    `json:"temp_hdd,omitempty"`
+	BoardName        string `json:"board_name,omitempty"`
+	TempSW           int    `json:"temp_sw,omitempty"`
+	Uptime           string `json:"uptime,omitempty"`
+	UptimeVal        int    `json:"uptime_val,omitempty"`
+	UserMainStorage  string `json:"user_main_storage,omitempty"`
+	BoxAuthenticated bool   `json:"box_authenticated,omitempty"`
+	Serial           string `json:"serial,omitempty"`
+	FirmwareVersion  string `json:"firmware_version,omitempty"`
 }
 
 // https://dev.freebox.fr/sdk/os/wifi/
 type wifiAccessPoint struct {
-	Name string `json:"name,omitempty"`
-	ID   int    `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	ID     int    `json:"id,omitempty"`
+	Status struct {
+		State               string `json:"state,omitempty"`
+		ChannelWidth        string `json:"channel_width,omitempty"`
+		PrimaryChannel      int    `json:"primary_channel,omitempty"`
+		SecondaryChannel    int    `json:"secondary_channel,omitempty"`
+		DfsCacRemainingTime int    `json:"dfs_cac_remaining_time,omitempty"`
+		DfsDisabled         bool   `json:"dfs_disabled,omitempty"`
+	} `json:"status,omitempty"`
+	Config struct {
+		Band         string `json:"band,omitempty"` // 2d4g|5g|6g|60g
+		ChannelWidth string `json:"channel_width,omitempty"`
+		DfsEnabled   bool   `json:"dfs_enabled,omitempty"`
+	} `json:"config,omitempty"`
+}
+
+type wifiChannelUsage struct {
+	Band          string `json:"band,omitempty"`
+	Channel       int    `json:"channel,omitempty"`
+	NoiseLevel    int    `json:"noise_level,omitempty"`
+	RxBusyPercent int    `json:"rx_busy_percent,omitempty"`
+	TxPercent     int    `json:"tx_percent,omitempty"`
+	BusyPercent   int    `json:"busy_percent,omitempty"`
 }
 
-type wifi struct {
-	Success bool              `json:"success"`
-	Result  []wifiAccessPoint `json:"result,omitempty"`
+type wifiNeighbor struct {
+	BSSID            string `json:"bssid,omitempty"`
+	SSID             string `json:"ssid,omitempty"`
+	Band             string `json:"band,omitempty"`
+	ChannelWidth     string `json:"channel_width,omitempty"`
+	Channel          int    `json:"channel,omitempty"`
+	SecondaryChannel int    `json:"secondary_channel,omitempty"`
+	Signal           int    `json:"signal,omitempty"`
+}
+
+type wifiBssParams struct {
+	Enabled    bool   `json:"enabled,omitempty"`
+	SSID       string `json:"ssid,omitempty"`
+	HideSSID   bool   `json:"hide_ssid,omitempty"`
+	Encryption string `json:"encryption,omitempty"`
+}
+
+type wifiBss struct {
+	ID     string `json:"id,omitempty"` // bssid
+	PhyID  int    `json:"phy_id,omitempty"`
+	Status struct {
+		State              string `json:"state,omitempty"`
+		StaCount           int    `json:"sta_count,omitempty"`
+		AuthorizedStaCount int    `json:"authorized_sta_count,omitempty"`
+		IsMainBss          bool   `json:"is_main_bss,omitempty"`
+	} `json:"status,omitempty"`
+	UseSharedParams bool          `json:"use_shared_params,omitempty"`
+	SharedBssParams wifiBssParams `json:"shared_bss_params,omitempty"`
+	BssParams       wifiBssParams `json:"bss_params,omitempty"`
+}
+
+// params returns the parameters in use by the BSS, the ones shared by
+// every band when it uses them
+func (b wifiBss) params() wifiBssParams {
+	if b.UseSharedParams {
+		return b.SharedBssParams
+	}
+	return b.BssParams
 }
 
 type wifiStation struct {
-	Hostname           string `json:"hostname,omitempty"`
-	MAC                string `json:"mac,omitempty"`
-	State              string `json:"state,omitempty"`
-	Inactive           int    `json:"inactive,omitempty"`
-	RXBytes            int64  `json:"rx_bytes,omitempty"`
-	TXBytes            int64  `json:"tx_bytes,omitempty"`
-	ConnectionDuration int    `json:"conn_duration,omitempty"`
-	TXRate             int64  `json:"tx_rate,omitempty"`
-	RXRate             int64  `json:"rx_rate,omitempty"`
-	Signal             int    `json:"signal,omitempty"`
+	Hostname           string           `json:"hostname,omitempty"`
+	MAC                string           `json:"mac,omitempty"`
+	State              string           `json:"state,omitempty"`
+	Inactive           int              `json:"inactive,omitempty"`
+	RXBytes            int64            `json:"rx_bytes,omitempty"`
+	TXBytes            int64            `json:"tx_bytes,omitempty"`
+	ConnectionDuration int              `json:"conn_duration,omitempty"`
+	TXRate             int64            `json:"tx_rate,omitempty"`
+	RXRate             int64            `json:"rx_rate,omitempty"`
+	Signal             int              `json:"signal,omitempty"`
+	TXRetries          int64            `json:"tx_retries,omitempty"`
+	TXFailed           int64            `json:"tx_failed,omitempty"`
+	Flags              wifiStationFlags `json:"flags,omitempty"`
+	LastRX             wifiStationRate  `json:"last_rx,omitempty"`
+	LastTX             wifiStationRate  `json:"last_tx,omitempty"`
+}
+
+type wifiStationFlags struct {
+	Legacy     bool `json:"legacy,omitempty"`
+	HT         bool `json:"ht,omitempty"`
+	VHT        bool `json:"vht,omitempty"`
+	HE         bool `json:"he,omitempty"`
+	Authorized bool `json:"authorized,omitempty"`
+	Powersave  bool `json:"powersave,omitempty"`
+}
+
+// standard returns the 802.11 amendment the station uses
+func (f wifiStationFlags) standard() string {
+	switch {
+	case f.HE:
+		return "ax"
+	case f.VHT:
+		return "ac"
+	case f.HT:
+		return "n"
+	}
+	return "legacy"
 }
 
-type wifiStations struct {
-	Success bool          `json:"success"`
-	Result  []wifiStation `json:"result,omitempty"`
+type wifiStationRate struct {
+	Bitrate int64  `json:"bitrate,omitempty"` // in 100 kbit/s
+	MCS     int    `json:"mcs,omitempty"`
+	VHTMCS  int    `json:"vht_mcs,omitempty"`
+	Width   string `json:"width,omitempty"`
+	Shortgi bool   `json:"shortgi,omitempty"`
+	NSS     int    `json:"nss,omitempty"`
+}
+
+// mcs returns the MCS index of the rate, the VHT/HE one when there is one
+func (r wifiStationRate) mcs() int {
+	if r.VHTMCS > 0 {
+		return r.VHTMCS
+	}
+	return r.MCS
 }
 
 type app struct {
-	AppID      string `json:"app_id"`
-	AppName    string `json:"app_name"`
-	AppVersion string `json:"app_version"`
-	DeviceName string `json:"device_name"`
+	AppID      string `json:"app_id" yaml:"id"`
+	AppName    string `json:"app_name" yaml:"name"`
+	AppVersion string `json:"app_version" yaml:"version"`
+	DeviceName string `json:"device_name" yaml:"device_name"`
 }
 
 type api struct {
@@ -244,33 +518,24 @@ type api struct {
 	loginSession string
 }
 
-type store struct {
-	location string
-}
-
 type authInfo struct {
-	myApp    app
-	myAPI    api
-	myStore  store
-	myReader *bufio.Reader
-}
-
-type postRequest struct {
-	method, url, header string
+	myApp      app
+	myAPI      api
+	myStore    tokenStore
+	myClient   *http.Client
+	myEndpoint string // endpoint recorded with a new app_token
+	myUID      string // uid of the Freebox the app_token must belong to
 }
 
 // https://dev.freebox.fr/sdk/os/vpn/
-type vpnServer struct {
-	Success bool `json:"success"`
-	Result  []struct {
-		RxBytes       int64  `json:"rx_bytes,omitempty"`
-		Authenticated bool   `json:"authenticated,omitempty"`
-		TxBytes       int64  `json:"tx_bytes,omitempty"`
-		User          string `json:"user,omitempty"`
-		ID            string `json:"id,omitempty"`
-		Vpn           string `json:"vpn,omitempty"`
-		SrcIP         string `json:"src_ip,omitempty"`
-		AuthTime      int32  `json:"auth_time,omitempty"`
-		LocalIP       string `json:"local_ip,omitempty"`
-	} `json:"result,omitempty"`
+type vpnConnection struct {
+	RxBytes       int64  `json:"rx_bytes,omitempty"`
+	Authenticated bool   `json:"authenticated,omitempty"`
+	TxBytes       int64  `json:"tx_bytes,omitempty"`
+	User          string `json:"user,omitempty"`
+	ID            string `json:"id,omitempty"`
+	Vpn           string `json:"vpn,omitempty"`
+	SrcIP         string `json:"src_ip,omitempty"`
+	AuthTime      int32  `json:"auth_time,omitempty"`
+	LocalIP       string `json:"local_ip,omitempty"`
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// errNoToken is returned by the stores which do not hold any app_token yet
+	errNoToken = errors.New("no app_token found")
+
+	// errReadOnlyStore is returned by the stores which cannot be written
+	errReadOnlyStore = errors.New("the token store is read-only")
+)
+
+// appToken is an app_token with the Freebox which issued it
+type appToken struct {
+	AppToken  string    `json:"app_token"`
+	AppID     string    `json:"app_id,omitempty"`
+	TrackID   int       `json:"track_id,omitempty"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	UID       string    `json:"uid,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// tokenStore keeps the app_token between two runs of the exporter
+type tokenStore interface {
+	load() (*appToken, error)
+	save(token *appToken) error
+	String() string
+}
+
+// newTokenStore parses a store specification: file:<path> for a JSON
+// file written by authorize, secret:<path> for a read-only file such
+// as a Kubernetes secret, env:<name> for an environment variable
+func newTokenStore(spec string) (tokenStore, error) {
+	kv := strings.SplitN(spec, ":", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return nil, fmt.Errorf("invalid token store %q, expected file:<path>, secret:<path> or env:<name>", spec)
+	}
+
+	switch kv[0] {
+	case "file":
+		return &fileStore{location: kv[1]}, nil
+	case "secret":
+		return &secretStore{location: kv[1]}, nil
+	case "env":
+		return &envStore{name: kv[1]}, nil
+	}
+	return nil, fmt.Errorf("unknown token store %q, expected file, secret or env", kv[0])
+}
+
+// parseToken reads a token in JSON or a bare app_token
+func parseToken(data []byte) (*appToken, error) {
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return nil, errNoToken
+	}
+
+	if !strings.HasPrefix(text, "{") {
+		return &appToken{AppToken: text}, nil
+	}
+
+	token := &appToken{}
+	if err := json.Unmarshal([]byte(text), token); err != nil {
+		return nil, err
+	}
+	if token.AppToken == "" {
+		return nil, errNoToken
+	}
+	return token, nil
+}
+
+// fileStore stores the app_token and its metadata in a JSON file
+type fileStore struct {
+	location string
+}
+
+func (s *fileStore) String() string {
+	return "file:" + s.location
+}
+
+func (s *fileStore) load() (*appToken, error) {
+	data, err := ioutil.ReadFile(s.location)
+	if os.IsNotExist(err) {
+		return nil, errNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseToken(data)
+}
+
+// save atomically replaces the file with token
+func (s *fileStore) save(token *appToken) error {
+	if s.location == "" {
+		return errors.New("no location to store the app_token")
+	}
+
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.location), ".freebox_token")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.location)
+}
+
+// secretStore reads the app_token from a file managed by someone else,
+// a Kubernetes secret for instance
+type secretStore struct {
+	location string
+}
+
+func (s *secretStore) String() string {
+	return "secret:" + s.location
+}
+
+func (s *secretStore) load() (*appToken, error) {
+	data, err := ioutil.ReadFile(s.location)
+	if os.IsNotExist(err) {
+		return nil, errNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseToken(data)
+}
+
+func (s *secretStore) save(token *appToken) error {
+	return fmt.Errorf("%s: %w", s, errReadOnlyStore)
+}
+
+// envStore reads the app_token from an environment variable
+type envStore struct {
+	name string
+}
+
+func (s *envStore) String() string {
+	return "env:" + s.name
+}
+
+func (s *envStore) load() (*appToken, error) {
+	return parseToken([]byte(os.Getenv(s.name)))
+}
+
+func (s *envStore) save(token *appToken) error {
+	return fmt.Errorf("%s: %w", s, errReadOnlyStore)
+}
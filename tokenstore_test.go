@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewTokenStore(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"file:/tmp/token":   "file:/tmp/token",
+		"secret:/tmp/token": "secret:/tmp/token",
+		"env:FREEBOX_TOKEN": "env:FREEBOX_TOKEN",
+	} {
+		s, err := newTokenStore(spec)
+		if err != nil {
+			t.Error("Expected no err, but got", err)
+			continue
+		}
+		if s.String() != expected {
+			t.Error("Expected", expected, "but got", s.String())
+		}
+	}
+
+	for _, spec := range []string{"", "file:", "/tmp/token", "vault:secret/freebox"} {
+		if _, err := newTokenStore(spec); err == nil {
+			t.Error("Expected an error for", spec)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	s := &fileStore{}
+	err := s.save(&appToken{AppToken: "IOI"})
+	if err.Error() != "no location to store the app_token" {
+		t.Error("Expected no location to store the app_token, but got", err)
+	}
+
+	s.location = "/tmp/token"
+	_, err = s.load()
+	if err != errNoToken {
+		t.Error("Expected no app_token found, but got", err)
+	}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = s.save(&appToken{
+		AppToken:  "IOI",
+		AppID:     "fr.freebox.exporter",
+		TrackID:   101,
+		Endpoint:  "http://mafreebox.freebox.fr/",
+		UID:       "0123456789abcdef",
+		CreatedAt: created,
+	})
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+	defer os.Remove(s.location)
+
+	token, err := s.load()
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	if token.AppToken != "IOI" {
+		t.Error("Expected IOI, but got", token.AppToken)
+	}
+
+	if token.TrackID != 101 {
+		t.Error("Expected 101, but got", token.TrackID)
+	}
+
+	if token.UID != "0123456789abcdef" {
+		t.Error("Expected 0123456789abcdef, but got", token.UID)
+	}
+
+	if !token.CreatedAt.Equal(created) {
+		t.Error("Expected", created, "but got", token.CreatedAt)
+	}
+
+	// the files written before the metadata are still read
+	ioutil.WriteFile(s.location, []byte("IOI\n"), 0600)
+	token, err = s.load()
+	if err != nil {
+		t.Error("Expected no err, but got", err)
+	}
+
+	if token.AppToken != "IOI" || token.UID != "" {
+		t.Error("Expected IOI without uid, but got", token)
+	}
+}
+
+func TestSecretStore(t *testing.T) {
+	s := &secretStore{location: "/tmp/token"}
+	_, err := s.load()
+	if err != errNoToken {
+		t.Error("Expected no app_token found, but got", err)
+	}
+
+	ioutil.WriteFile(s.location, []byte(`{"app_token":"IOI","uid":"0123456789abcdef"}`), 0400)
+	defer os.Remove(s.location)
+
+	token, err := s.load()
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	if token.AppToken != "IOI" {
+		t.Error("Expected IOI, but got", token.AppToken)
+	}
+
+	err = s.save(token)
+	if !errors.Is(err, errReadOnlyStore) {
+		t.Error("Expected the token store is read-only, but got", err)
+	}
+}
+
+func TestEnvStore(t *testing.T) {
+	s := &envStore{name: "FREEBOX_TOKEN"}
+	os.Unsetenv(s.name)
+	_, err := s.load()
+	if err != errNoToken {
+		t.Error("Expected no app_token found, but got", err)
+	}
+
+	os.Setenv(s.name, "IOI")
+	defer os.Unsetenv(s.name)
+
+	token, err := s.load()
+	if err != nil {
+		t.Fatal("Expected no err, but got", err)
+	}
+
+	if token.AppToken != "IOI" {
+		t.Error("Expected IOI, but got", token.AppToken)
+	}
+
+	err = s.save(token)
+	if !errors.Is(err, errReadOnlyStore) {
+		t.Error("Expected the token store is read-only, but got", err)
+	}
+}